@@ -0,0 +1,201 @@
+package hermes
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen returned when the circuit breaker is open, i.e. the database
+// connection failed repeatedly and Hermes is failing fast rather than waiting
+// on the connection.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// How long to wait on the ping that tests whether to close the circuit.
+const probeTimeout = 5 * time.Second
+
+// CircuitState is the state of the circuit breaker.
+type CircuitState int
+
+// The states of the circuit breaker.
+const (
+	// CircuitClosed lets statements through to the database.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen fails statements immediately with ErrCircuitOpen.
+	CircuitOpen
+
+	// CircuitHalfOpen means Hermes is pinging the database to see if it has
+	// recovered.  Statements still fail with ErrCircuitOpen.
+	CircuitHalfOpen
+)
+
+var circuitNames = map[CircuitState]string{
+	CircuitClosed:   "closed",
+	CircuitOpen:     "open",
+	CircuitHalfOpen: "half-open",
+}
+
+// String returns the name of the state, e.g. "half-open".
+func (s CircuitState) String() string {
+	if name, ok := circuitNames[s]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// CircuitFn is called when the circuit breaker changes state.
+type CircuitFn func(db *DB, state CircuitState)
+
+// WithCircuitBreaker opens the circuit after the given number of consecutive
+// connection failures (see DidConnectionFail) or failures to confirm the
+// connection (see WithConfirm).  While the circuit is open, queries and
+// transactions fail immediately with ErrCircuitOpen, rather than piling up
+// waiting on connect timeouts.  After the cooldown, the next query half-opens
+// the circuit and pings the database, waiting up to five seconds:  if the ping
+// succeeds, the circuit closes and the query goes through; otherwise the
+// circuit opens for another cooldown.
+func WithCircuitBreaker(failures int, cooldown time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.CircuitFailures = failures
+		cfg.CircuitCooldown = cooldown
+	}
+}
+
+// WithCircuitChange sets the function called when the circuit breaker opens,
+// half-opens, or closes.
+func WithCircuitChange(fn CircuitFn) Option {
+	return func(cfg *Config) {
+		cfg.OnCircuitChange = fn
+	}
+}
+
+// Trips after consecutive connection failures.  See WithCircuitBreaker.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int       // consecutive connection failures while closed
+	opened   time.Time // when the circuit last opened
+}
+
+// CircuitState returns the state of the circuit breaker.  Always returns
+// CircuitClosed if the database isn't configured WithCircuitBreaker.
+func (db *DB) CircuitState() CircuitState {
+	if db.breaker == nil {
+		return CircuitClosed
+	}
+
+	db.breaker.mu.Lock()
+	defer db.breaker.mu.Unlock()
+
+	return db.breaker.state
+}
+
+// Returns ErrCircuitOpen if the circuit is open.  Once the cooldown has
+// passed, the first caller half-opens the circuit and pings the database,
+// closing the circuit if the ping succeeds.  Other callers fail fast while
+// the ping is running.
+func (db *DB) allow() error {
+	b := db.breaker
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	if b.state == CircuitClosed {
+		b.mu.Unlock()
+		return nil
+	}
+
+	if b.state == CircuitHalfOpen || time.Since(b.opened) < b.cooldown {
+		b.mu.Unlock()
+		return ErrCircuitOpen
+	}
+
+	b.state = CircuitHalfOpen
+	b.mu.Unlock()
+
+	db.circuitChanged(CircuitHalfOpen)
+
+	err := db.probe()
+
+	b.mu.Lock()
+	if err != nil {
+		b.state = CircuitOpen
+		b.opened = time.Now()
+	} else {
+		b.state = CircuitClosed
+		b.failures = 0
+	}
+	state := b.state
+	b.mu.Unlock()
+
+	db.circuitChanged(state)
+
+	if err != nil {
+		return ErrCircuitOpen
+	}
+
+	return nil
+}
+
+// Records the result of a statement.  Opens the circuit after too many
+// consecutive connection failures; any other result resets the count.
+func (db *DB) recordResult(failed bool) {
+	b := db.breaker
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	if b.state != CircuitClosed {
+		b.mu.Unlock()
+		return
+	}
+
+	if !failed {
+		b.failures = 0
+		b.mu.Unlock()
+		return
+	}
+
+	b.failures++
+	if b.failures < b.threshold {
+		b.mu.Unlock()
+		return
+	}
+
+	b.state = CircuitOpen
+	b.opened = time.Now()
+	b.mu.Unlock()
+
+	db.circuitChanged(CircuitOpen)
+}
+
+// Pings the database for the half-open circuit, giving up after the probe
+// timeout.
+func (db *DB) probe() error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	return db.base().PingContext(ctx)
+}
+
+// Logs the change in state and calls the OnCircuitChange function.
+func (db *DB) circuitChanged(state CircuitState) {
+	level := LogInfo
+	if state == CircuitOpen {
+		level = LogWarn
+	}
+
+	db.logger().Log(level, "Database circuit breaker "+state.String())
+
+	if db.config.OnCircuitChange != nil {
+		db.config.OnCircuitChange(db, state)
+	}
+}
@@ -0,0 +1,117 @@
+package hermes_test
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var states []hermes.CircuitState
+
+	mock := hermestest.New()
+	db := mock.DB(
+		hermes.WithLogger(hermes.StdLogger(log.New(ioutil.Discard, "", 0), hermes.LogError)),
+		hermes.WithCircuitBreaker(2, 20*time.Millisecond),
+		hermes.WithCircuitChange(func(db *hermes.DB, state hermes.CircuitState) {
+			states = append(states, state)
+		}))
+	defer db.Close()
+
+	failed := &pq.Error{Code: "08006"}
+
+	// Any other result resets the count of consecutive failures
+	mock.ExpectExec("update samples").WillReturnError(failed)
+	mock.ExpectExec("update samples").WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectExec("update samples").WillReturnError(failed)
+	mock.ExpectExec("update samples").WillReturnError(failed)
+	mock.ExpectExec("update samples")
+
+	for idx := 0; idx < 3; idx++ {
+		_, _ = db.Exec("update samples set name = 'Bob'")
+
+		if db.CircuitState() != hermes.CircuitClosed {
+			t.Fatalf("Expected the circuit to stay closed after statement %d", idx+1)
+		}
+	}
+
+	_, _ = db.Exec("update samples set name = 'Bob'")
+
+	if db.CircuitState() != hermes.CircuitOpen {
+		t.Fatalf("Expected the circuit to open; was %s", db.CircuitState())
+	}
+
+	if _, err := db.Exec("update samples set name = 'Bob'"); err != hermes.ErrCircuitOpen {
+		t.Errorf(`Expected error "%s"; got "%v"`, hermes.ErrCircuitOpen, err)
+	}
+
+	if _, err := db.Begin(); err != hermes.ErrCircuitOpen {
+		t.Errorf(`Expected error "%s"; got "%v"`, hermes.ErrCircuitOpen, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, err := db.Exec("update samples set name = 'Bob'"); err != nil {
+		t.Fatalf("Expected the circuit to close after the cooldown: %s", err)
+	}
+
+	expected := []hermes.CircuitState{hermes.CircuitOpen, hermes.CircuitHalfOpen, hermes.CircuitClosed}
+	if len(states) != len(expected) {
+		t.Fatalf("Expected states %v; got %v", expected, states)
+	}
+
+	for idx, state := range expected {
+		if states[idx] != state {
+			t.Errorf("Expected states %v; got %v", expected, states)
+			break
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCircuitBreakerConfirm(t *testing.T) {
+	db, err := hermes.ConnectUnchecked(driver, "postgres://postgres@127.0.0.1/nemo?sslmode=disable&connect_timeout=10", 5, 1,
+		hermes.WithLogger(hermes.StdLogger(log.New(ioutil.Discard, "", 0), hermes.LogError)),
+		hermes.WithConfirm(1),
+		hermes.WithCircuitBreaker(2, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for idx := 0; idx < 2; idx++ {
+		if _, err := db.Exec("select 1"); err != hermes.ErrUnavailable {
+			t.Fatalf(`Expected error "%s"; got "%v"`, hermes.ErrUnavailable, err)
+		}
+	}
+
+	if db.CircuitState() != hermes.CircuitOpen {
+		t.Fatalf("Expected failed confirmations to open the circuit; was %s", db.CircuitState())
+	}
+
+	if _, err := db.Exec("select 1"); err != hermes.ErrCircuitOpen {
+		t.Errorf(`Expected error "%s"; got "%v"`, hermes.ErrCircuitOpen, err)
+	}
+}
+
+func TestCircuitState(t *testing.T) {
+	tests := map[hermes.CircuitState]string{
+		hermes.CircuitClosed:   "closed",
+		hermes.CircuitOpen:     "open",
+		hermes.CircuitHalfOpen: "half-open",
+	}
+
+	for state, name := range tests {
+		if state.String() != name {
+			t.Errorf("Expected %s; got %s", name, state)
+		}
+	}
+}
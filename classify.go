@@ -0,0 +1,216 @@
+package hermes
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+// Kind classifies the errors returned by the database.  See Classify.
+type Kind int
+
+// The kinds of database errors.
+const (
+	// KindUnknown is any error not covered by the other kinds, including
+	// nil.
+	KindUnknown Kind = iota
+
+	// KindConnection is a connection or server failure.  See
+	// DidConnectionFail.
+	KindConnection
+
+	// KindUniqueViolation is a unique constraint violation (23505).
+	KindUniqueViolation
+
+	// KindForeignKeyViolation is a foreign key constraint violation (23503).
+	KindForeignKeyViolation
+
+	// KindCheckViolation is a check constraint violation (23514).
+	KindCheckViolation
+
+	// KindNotNullViolation is a not null constraint violation (23502).
+	KindNotNullViolation
+
+	// KindLockTimeout means a lock couldn't be acquired (55P03), e.g. the
+	// lock_timeout expired or NOWAIT was used.
+	KindLockTimeout
+
+	// KindQueryCanceled means the query was canceled (57014), e.g. by the
+	// statement_timeout, or the context was canceled or timed out.
+	KindQueryCanceled
+
+	// KindSerializationFailure means a serializable transaction conflicted
+	// with another transaction (40001).  Retryable.
+	KindSerializationFailure
+
+	// KindDeadlock means the transaction deadlocked with another
+	// transaction (40P01).  Retryable.
+	KindDeadlock
+)
+
+var kindNames = map[Kind]string{
+	KindUnknown:              "unknown",
+	KindConnection:           "connection",
+	KindUniqueViolation:      "unique_violation",
+	KindForeignKeyViolation:  "foreign_key_violation",
+	KindCheckViolation:       "check_violation",
+	KindNotNullViolation:     "not_null_violation",
+	KindLockTimeout:          "lock_timeout",
+	KindQueryCanceled:        "query_canceled",
+	KindSerializationFailure: "serialization_failure",
+	KindDeadlock:             "deadlock",
+}
+
+// String returns the name of the kind of error, e.g. "unique_violation".
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// Classify returns the kind of error returned by the database.  Looks through
+// wrapped errors for the underlying lib/pq error.
+func Classify(err error) Kind {
+	if err == nil {
+		return KindUnknown
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return KindQueryCanceled
+	}
+
+	var e *pq.Error
+	if errors.As(err, &e) {
+		switch e.Code {
+		case "23505":
+			return KindUniqueViolation
+		case "23503":
+			return KindForeignKeyViolation
+		case "23514":
+			return KindCheckViolation
+		case "23502":
+			return KindNotNullViolation
+		case "55P03":
+			return KindLockTimeout
+		case "57014":
+			return KindQueryCanceled
+		case "40001":
+			return KindSerializationFailure
+		case "40P01":
+			return KindDeadlock
+		}
+	}
+
+	if DidConnectionFail(err) {
+		return KindConnection
+	}
+
+	return KindUnknown
+}
+
+// IsUniqueViolation returns true if the error is a unique constraint violation.
+func IsUniqueViolation(err error) bool {
+	return Classify(err) == KindUniqueViolation
+}
+
+// IsForeignKeyViolation returns true if the error is a foreign key constraint
+// violation.
+func IsForeignKeyViolation(err error) bool {
+	return Classify(err) == KindForeignKeyViolation
+}
+
+// IsCheckViolation returns true if the error is a check constraint violation.
+func IsCheckViolation(err error) bool {
+	return Classify(err) == KindCheckViolation
+}
+
+// IsNotNullViolation returns true if the error is a not null constraint
+// violation.
+func IsNotNullViolation(err error) bool {
+	return Classify(err) == KindNotNullViolation
+}
+
+// IsLockTimeout returns true if the error means a lock couldn't be acquired.
+func IsLockTimeout(err error) bool {
+	return Classify(err) == KindLockTimeout
+}
+
+// IsQueryCanceled returns true if the query was canceled, either by the
+// database or because its context was canceled or timed out.
+func IsQueryCanceled(err error) bool {
+	return Classify(err) == KindQueryCanceled
+}
+
+// IsSerializationFailure returns true if the error is a serialization failure.
+func IsSerializationFailure(err error) bool {
+	return Classify(err) == KindSerializationFailure
+}
+
+// IsDeadlock returns true if the transaction deadlocked.
+func IsDeadlock(err error) bool {
+	return Classify(err) == KindDeadlock
+}
+
+// Constraint returns the name of the constraint the error violated, or a blank
+// string if the error isn't a constraint violation.
+func Constraint(err error) string {
+	var e *pq.Error
+	if errors.As(err, &e) {
+		return e.Constraint
+	}
+
+	return ""
+}
+
+// Table returns the name of the table associated with the error, or a blank
+// string if the database didn't report one.
+func Table(err error) string {
+	var e *pq.Error
+	if errors.As(err, &e) {
+		return e.Table
+	}
+
+	return ""
+}
+
+// Column returns the name of the column associated with the error, e.g. for a
+// not null violation, or a blank string if the database didn't report one.
+func Column(err error) string {
+	var e *pq.Error
+	if errors.As(err, &e) {
+		return e.Column
+	}
+
+	return ""
+}
+
+// ErrorClass returns the SQLSTATE class of the error, e.g. "23" for an integrity
+// constraint violation or "08" for a connection exception, for grouping errors
+// in metrics or logs.  Errors that didn't come from the database are reported
+// as "network" for network failures, "canceled" for canceled or timed out
+// contexts, or "other".  Returns a blank string for nil.
+func ErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var e *pq.Error
+	if errors.As(err, &e) && len(e.Code) >= 2 {
+		return string(e.Code.Class())
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return "network"
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return "canceled"
+	}
+
+	return "other"
+}
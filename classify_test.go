@@ -0,0 +1,117 @@
+package hermes_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/sbowman/hermes"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind hermes.Kind
+	}{
+		{nil, hermes.KindUnknown},
+		{errors.New("oops"), hermes.KindUnknown},
+		{&pq.Error{Code: "23505"}, hermes.KindUniqueViolation},
+		{&pq.Error{Code: "23503"}, hermes.KindForeignKeyViolation},
+		{&pq.Error{Code: "23514"}, hermes.KindCheckViolation},
+		{&pq.Error{Code: "23502"}, hermes.KindNotNullViolation},
+		{&pq.Error{Code: "55P03"}, hermes.KindLockTimeout},
+		{&pq.Error{Code: "57014"}, hermes.KindQueryCanceled},
+		{&pq.Error{Code: "40001"}, hermes.KindSerializationFailure},
+		{&pq.Error{Code: "40P01"}, hermes.KindDeadlock},
+		{&pq.Error{Code: "08006"}, hermes.KindConnection},
+		{&pq.Error{Code: "42P01"}, hermes.KindUnknown},
+		{context.DeadlineExceeded, hermes.KindQueryCanceled},
+		{fmt.Errorf("saving user: %w", &pq.Error{Code: "23505"}), hermes.KindUniqueViolation},
+	}
+
+	for _, test := range tests {
+		if kind := hermes.Classify(test.err); kind != test.kind {
+			t.Errorf("Expected %v to be %s; was %s", test.err, test.kind, kind)
+		}
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err   error
+		class string
+	}{
+		{nil, ""},
+		{errors.New("oops"), "other"},
+		{&pq.Error{Code: "23505"}, "23"},
+		{fmt.Errorf("saving user: %w", &pq.Error{Code: "08006"}), "08"},
+		{&net.OpError{Op: "dial", Err: errors.New("refused")}, "network"},
+		{context.Canceled, "canceled"},
+	}
+
+	for _, test := range tests {
+		if class := hermes.ErrorClass(test.err); class != test.class {
+			t.Errorf("Expected %v to be class %q; was %q", test.err, test.class, class)
+		}
+	}
+}
+
+func TestConstraintDetails(t *testing.T) {
+	err := fmt.Errorf("saving user: %w", &pq.Error{
+		Code:       "23505",
+		Table:      "users",
+		Column:     "email",
+		Constraint: "users_email_key",
+	})
+
+	if !hermes.IsUniqueViolation(err) {
+		t.Error("Expected a unique violation")
+	}
+
+	if hermes.Constraint(err) != "users_email_key" {
+		t.Errorf("Expected constraint users_email_key; was %s", hermes.Constraint(err))
+	}
+
+	if hermes.Table(err) != "users" {
+		t.Errorf("Expected table users; was %s", hermes.Table(err))
+	}
+
+	if hermes.Column(err) != "email" {
+		t.Errorf("Expected column email; was %s", hermes.Column(err))
+	}
+
+	if hermes.Constraint(errors.New("oops")) != "" {
+		t.Error("Expected no constraint for a non-database error")
+	}
+}
+
+func TestUniqueViolation(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction :%s", err)
+	}
+	defer tx.Close()
+
+	if _, err := tx.Exec("create table test_unique(name varchar(64) constraint test_unique_name unique)"); err != nil {
+		t.Fatalf("Unable to create test_unique table: %s", err)
+	}
+
+	if _, err := tx.Exec("insert into test_unique values ('Bob')"); err != nil {
+		t.Fatalf("Unable to insert into test_unique: %s", err)
+	}
+
+	_, err = tx.Exec("insert into test_unique values ('Bob')")
+	if !hermes.IsUniqueViolation(err) {
+		t.Fatalf("Expected a unique violation; got %v", err)
+	}
+
+	if hermes.Constraint(err) != "test_unique_name" {
+		t.Errorf("Expected constraint test_unique_name; was %s", hermes.Constraint(err))
+	}
+}
@@ -0,0 +1,397 @@
+package hermes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Cluster routes statements across a primary database and its read replicas.
+// Implements the hermes.Conn interface.
+//
+// Transactions, Exec, and Prepare always go to the primary, as do all the
+// statements in a transaction.  Query, Row, Get, and Select outside of a
+// transaction are spread round-robin across the healthy replicas, falling
+// back to the primary if no replica is available.  Use UsePrimary to read
+// from the primary, e.g. right after a write.
+//
+// A replica is only known to be unhealthy if its health monitor is running,
+// so configure the replicas WithHealthCheck.  To skip replicas that have
+// fallen behind, configure the primary WithReplicaLag.
+type Cluster struct {
+	next     uint64 // round-robin counter; access atomically
+	primary  *DB
+	replicas []*replica
+	maxLag   time.Duration
+
+	done     chan struct{} // closed to stop the lag monitor
+	stopOnce sync.Once
+}
+
+type usePrimaryKey struct{}
+
+// UsePrimary returns a context that directs a Cluster to read from the primary
+// database rather than a replica.  Use it when reading data just written, which
+// may not have reached the replicas yet.
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, usePrimaryKey{}, true)
+}
+
+// Returns true if the context was created with UsePrimary.
+func usesPrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+
+	primary, _ := ctx.Value(usePrimaryKey{}).(bool)
+	return primary
+}
+
+// NewCluster creates a cluster from existing database connections.  The
+// replicas are optional; without them, the cluster sends everything to the
+// primary.  If the primary was configured WithReplicaLag, starts the lag
+// monitor.
+func NewCluster(primary *DB, replicas ...*DB) *Cluster {
+	c := &Cluster{
+		primary: primary,
+		maxLag:  primary.config.MaxReplicaLag,
+	}
+
+	for _, db := range replicas {
+		c.replicas = append(c.replicas, &replica{db: db})
+	}
+
+	if primary.config.ReplicaLagInterval > 0 && len(replicas) > 0 {
+		c.startLagMonitor(primary.config.ReplicaLagInterval)
+	}
+
+	return c
+}
+
+// ConnectCluster connects to the primary and each of the replicas, pinging
+// each.  Options apply to every database connection.  If any connection
+// fails, closes the others and returns the error.
+func ConnectCluster(driverName, primaryDSN string, replicaDSNs []string, maxOpen, maxIdle int, opts ...Option) (*Cluster, error) {
+	primary, err := Connect(driverName, primaryDSN, maxOpen, maxIdle, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var replicas []*DB
+
+	for _, dsn := range replicaDSNs {
+		replica, err := Connect(driverName, dsn, maxOpen, maxIdle, opts...)
+		if err != nil {
+			_ = primary.Close()
+			for _, replica := range replicas {
+				_ = replica.Close()
+			}
+
+			return nil, err
+		}
+
+		replicas = append(replicas, replica)
+	}
+
+	return NewCluster(primary, replicas...), nil
+}
+
+// Primary returns the primary database connection.
+func (c *Cluster) Primary() *DB {
+	return c.primary
+}
+
+// Replicas returns the replica database connections.
+func (c *Cluster) Replicas() []*DB {
+	dbs := make([]*DB, len(c.replicas))
+	for idx, r := range c.replicas {
+		dbs[idx] = r.db
+	}
+
+	return dbs
+}
+
+// Returns the database to read from:  the primary if requested by the context,
+// otherwise the next healthy replica that isn't lagging and has caught up to
+// the context's session.  Falls back to the primary if none of the replicas
+// are available.
+func (c *Cluster) reader(ctx context.Context) *DB {
+	if len(c.replicas) == 0 || usesPrimary(ctx) {
+		return c.primary
+	}
+
+	var position uint64
+	if session := sessionFrom(ctx); session != nil {
+		position = uint64(session.LSN())
+	}
+
+	// Without the lag monitor, replicas that appear behind the session are
+	// checked, all within a single deadline
+	var check context.Context
+	if position > 0 && c.done == nil {
+		var cancel context.CancelFunc
+		check, cancel = context.WithTimeout(ctx, positionCheckTimeout)
+		defer cancel()
+	}
+
+	start := atomic.AddUint64(&c.next, 1) - 1
+	for idx := range c.replicas {
+		r := c.replicas[(start+uint64(idx))%uint64(len(c.replicas))]
+		if r.db.Healthy() && c.caughtUp(check, r, position) {
+			return r.db
+		}
+	}
+
+	return c.primary
+}
+
+// Runs the read on a replica.  If the replica became unavailable since it was
+// selected, or its circuit breaker is open, runs the read on the primary
+// instead.
+func (c *Cluster) read(ctx context.Context, fn func(db *DB) error) error {
+	db := c.reader(ctx)
+
+	err := fn(db)
+	if db != c.primary && (errors.Is(err, ErrUnavailable) || errors.Is(err, ErrCircuitOpen)) {
+		return fn(c.primary)
+	}
+
+	return err
+}
+
+// Ping the primary and every replica, returning the first error.
+func (c *Cluster) Ping() error {
+	if err := c.primary.Ping(); err != nil {
+		return err
+	}
+
+	for _, r := range c.replicas {
+		if err := r.db.Ping(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BaseDB returns the primary's base database connection.
+func (c *Cluster) BaseDB() *sqlx.DB {
+	return c.primary.BaseDB()
+}
+
+// BaseTx returns nil.
+func (c *Cluster) BaseTx() *sqlx.Tx {
+	return nil
+}
+
+// Context returns nil; the cluster isn't associated with a context.
+func (c *Cluster) Context() context.Context {
+	return nil
+}
+
+// Returns the primary's retry policy, for InTx.
+func (c *Cluster) retryPolicy() RetryPolicy {
+	return c.primary.retryPolicy()
+}
+
+// Begin a new transaction on the primary.  Returns a Conn wrapping the
+// transaction (*sqlx.Tx).
+func (c *Cluster) Begin() (Conn, error) {
+	return c.primary.begin(nil, nil)
+}
+
+// BeginCtx begins a new transaction on the primary in context.  The Conn will
+// have the context associated with it and use it for all subsequent commands.
+// If the context has a session, records the commit in the session.
+func (c *Cluster) BeginCtx(ctx context.Context) (Conn, error) {
+	tx, err := c.primary.begin(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.trackCommit(ctx, tx)
+	return tx, nil
+}
+
+// BeginTx begins a new transaction on the primary in context with the given
+// options.  Read-only transactions also run on the primary, so they see
+// consistent, current data.  If the context has a session, records the commit
+// in the session.
+func (c *Cluster) BeginTx(ctx context.Context, opts *sql.TxOptions) (Conn, error) {
+	tx, err := c.primary.begin(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts == nil || !opts.ReadOnly {
+		c.trackCommit(ctx, tx)
+	}
+
+	return tx, nil
+}
+
+// Exec executes a database statement with no results on the primary.
+func (c *Cluster) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.primary.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext executes a database statement with no results on the primary,
+// using the context to cancel the request.  If the context has a session,
+// records the write in the session.
+func (c *Cluster) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	res, err := c.primary.ExecContext(ctx, query, args...)
+	if err != nil {
+		return res, err
+	}
+
+	if session := sessionFrom(ctx); session != nil {
+		c.recordWrite(session)
+	}
+
+	return res, nil
+}
+
+// Query a replica.
+func (c *Cluster) Query(query string, args ...interface{}) (*sqlx.Rows, error) {
+	return c.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext queries a replica, or the primary if the context was created
+// with UsePrimary, using the context to cancel the request.
+func (c *Cluster) QueryContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	var rows *sqlx.Rows
+
+	err := c.read(ctx, func(db *DB) (err error) {
+		rows, err = db.QueryContext(ctx, query, args...)
+		return err
+	})
+
+	return rows, err
+}
+
+// Row returns the results for a single row from a replica.
+func (c *Cluster) Row(query string, args ...interface{}) (*sqlx.Row, error) {
+	return c.RowContext(context.Background(), query, args...)
+}
+
+// RowContext returns the results for a single row from a replica, or the
+// primary if the context was created with UsePrimary, using the context to
+// cancel the request.
+func (c *Cluster) RowContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Row, error) {
+	var row *sqlx.Row
+
+	err := c.read(ctx, func(db *DB) (err error) {
+		row, err = db.RowContext(ctx, query, args...)
+		return err
+	})
+
+	return row, err
+}
+
+// Prepare a database query on the primary.
+func (c *Cluster) Prepare(query string) (*sqlx.Stmt, error) {
+	return c.primary.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext prepares a database query on the primary, using the context
+// to cancel the request.
+func (c *Cluster) PrepareContext(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	return c.primary.PrepareContext(ctx, query)
+}
+
+// Get a single record from a replica, e.g. "SELECT ... LIMIT 1".
+func (c *Cluster) Get(dest interface{}, query string, args ...interface{}) error {
+	return c.GetContext(context.Background(), dest, query, args...)
+}
+
+// GetContext gets a single record from a replica, or the primary if the
+// context was created with UsePrimary, using the context to cancel the
+// request.
+func (c *Cluster) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return c.read(ctx, func(db *DB) error {
+		return db.GetContext(ctx, dest, query, args...)
+	})
+}
+
+// Select a collection of records from a replica.
+func (c *Cluster) Select(dest interface{}, query string, args ...interface{}) error {
+	return c.SelectContext(context.Background(), dest, query, args...)
+}
+
+// SelectContext selects a collection of records from a replica, or the primary
+// if the context was created with UsePrimary, using the context to cancel the
+// request.
+func (c *Cluster) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return c.read(ctx, func(db *DB) error {
+		return db.SelectContext(ctx, dest, query, args...)
+	})
+}
+
+// Commit does nothing in a cluster.
+func (c *Cluster) Commit() error {
+	return nil
+}
+
+// Rollback does nothing in a cluster.
+func (c *Cluster) Rollback() error {
+	return nil
+}
+
+// Close stops the lag monitor, if running, and closes the primary and replica
+// database connections, returning the first error.
+func (c *Cluster) Close() error {
+	c.stopLagMonitor()
+
+	err := c.primary.Close()
+
+	for _, r := range c.replicas {
+		if rerr := r.db.Close(); err == nil {
+			err = rerr
+		}
+	}
+
+	return err
+}
+
+// OnCommit calls the function immediately; there's no transaction to wait on.
+func (c *Cluster) OnCommit(fn func()) {
+	c.primary.OnCommit(fn)
+}
+
+// OnRollback does nothing in a cluster.
+func (c *Cluster) OnRollback(fn func()) {}
+
+// RolledBack always returns false.
+func (c *Cluster) RolledBack() bool {
+	return false
+}
+
+// Name returns the primary's datasource name.
+func (c *Cluster) Name() string {
+	return c.primary.Name()
+}
+
+// Savepoint does nothing in a cluster.
+func (c *Cluster) Savepoint() (string, error) {
+	return c.primary.Savepoint()
+}
+
+// NamedSavepoint only validates the savepoint name in a cluster.
+func (c *Cluster) NamedSavepoint(name string) error {
+	return c.primary.NamedSavepoint(name)
+}
+
+// RollbackTo does nothing in a cluster.
+func (c *Cluster) RollbackTo(savepointID string) error {
+	return c.primary.RollbackTo(savepointID)
+}
+
+// ReleaseSavepoint does nothing in a cluster.
+func (c *Cluster) ReleaseSavepoint(savepointID string) error {
+	return c.primary.ReleaseSavepoint(savepointID)
+}
@@ -0,0 +1,147 @@
+package hermes_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+func TestCluster(t *testing.T) {
+	primary, replica1, replica2 := hermestest.New(), hermestest.New(), hermestest.New()
+
+	cluster := hermes.NewCluster(primary.DB(), replica1.DB(), replica2.DB())
+	defer cluster.Close()
+
+	names := func(name string) *hermestest.Rows {
+		return hermestest.NewRows("name").AddRow(name)
+	}
+
+	replica1.ExpectQuery("select name").WillReturnRows(names("one"))
+	replica2.ExpectQuery("select name").WillReturnRows(names("two"))
+	replica1.ExpectQuery("select name").WillReturnRows(names("one"))
+
+	primary.ExpectExec("update samples")
+	primary.ExpectQuery("select name").WillReturnRows(names("primary"))
+	primary.ExpectBegin()
+	primary.ExpectQuery("select name").WillReturnRows(names("primary"))
+	primary.ExpectCommit()
+
+	for _, expected := range []string{"one", "two", "one"} {
+		var name string
+		if err := cluster.Get(&name, "select name from samples"); err != nil {
+			t.Fatalf("Unable to read from a replica: %s", err)
+		}
+
+		if name != expected {
+			t.Errorf("Expected to read from replica %s; got %s", expected, name)
+		}
+	}
+
+	if _, err := cluster.Exec("update samples set name = 'Bob'"); err != nil {
+		t.Fatalf("Unable to write to the primary: %s", err)
+	}
+
+	var name string
+	if err := cluster.GetContext(hermes.UsePrimary(context.Background()), &name, "select name from samples"); err != nil {
+		t.Fatalf("Unable to read from the primary: %s", err)
+	}
+
+	if name != "primary" {
+		t.Errorf("Expected UsePrimary to read from the primary; got %s", name)
+	}
+
+	tx, err := cluster.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+	defer tx.Close()
+
+	if err := tx.Get(&name, "select name from samples"); err != nil {
+		t.Fatalf("Unable to read in the transaction: %s", err)
+	}
+
+	if name != "primary" {
+		t.Errorf("Expected the transaction to read from the primary; got %s", name)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Unable to commit: %s", err)
+	}
+
+	for _, mock := range []*hermestest.Mock{primary, replica1, replica2} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestClusterUnhealthyReplica(t *testing.T) {
+	primary, replica1, replica2 := hermestest.New(), hermestest.New(), hermestest.New()
+
+	// The monitor only needs to be running to mark the replica unhealthy
+	monitored := hermes.WithHealthCheck(time.Hour, 0)
+
+	cluster := hermes.NewCluster(primary.DB(), replica1.DB(monitored), replica2.DB(monitored))
+	defer cluster.Close()
+
+	replica1.ExpectQuery("select name").WillReturnError(&pq.Error{Code: "08006"})
+	replica2.ExpectQuery("select name").WillReturnRows(hermestest.NewRows("name").AddRow("two"))
+	replica2.ExpectQuery("select name").WillReturnError(&pq.Error{Code: "08006"})
+	primary.ExpectQuery("select name").WillReturnRows(hermestest.NewRows("name").AddRow("primary"))
+
+	var name string
+	if err := cluster.Get(&name, "select name from samples"); !hermes.DidConnectionFail(err) {
+		t.Fatalf("Expected a connection failure; got %v", err)
+	}
+
+	if cluster.Replicas()[0].Healthy() {
+		t.Error("Expected the replica to be unhealthy")
+	}
+
+	if err := cluster.Get(&name, "select name from samples"); err != nil || name != "two" {
+		t.Errorf("Expected to skip the unhealthy replica; got %s, %v", name, err)
+	}
+
+	_ = cluster.Get(&name, "select name from samples")
+
+	if err := cluster.Get(&name, "select name from samples"); err != nil || name != "primary" {
+		t.Errorf("Expected to fall back to the primary; got %s, %v", name, err)
+	}
+
+	for _, mock := range []*hermestest.Mock{primary, replica1, replica2} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestClusterInTxRetry(t *testing.T) {
+	primary, replica := hermestest.New(), hermestest.New()
+
+	cluster := hermes.NewCluster(primary.DB(hermes.WithRetry(hermes.RetryPolicy{Attempts: 2})), replica.DB())
+	defer cluster.Close()
+
+	for idx := 0; idx < 2; idx++ {
+		primary.ExpectBegin()
+		primary.ExpectExec("update samples").WillReturnError(&pq.Error{Code: "40001"})
+		primary.ExpectRollback()
+	}
+
+	err := hermes.InTx(context.Background(), cluster, nil, func(conn hermes.Conn) error {
+		_, err := conn.Exec("update samples set name = 'Bob'")
+		return err
+	})
+	if !hermes.IsSerializationFailure(err) {
+		t.Errorf("Expected a serialization failure; got %v", err)
+	}
+
+	for _, mock := range []*hermestest.Mock{primary, replica} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
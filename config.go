@@ -0,0 +1,248 @@
+package hermes
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Timeouts configures the transaction timer, which warns you about long-lived
+// transactions.  See TxTimeout.
+type Timeouts struct {
+	// Enabled must be set to true to enable transaction timers.
+	Enabled bool
+
+	// Duration is the time to wait in milliseconds before reporting
+	// a transaction being left open.
+	Duration time.Duration
+
+	// Panic set to true causes Hermes to panic if the transaction
+	// remains open past its duration.  When false, Hermes simply
+	// writes a message to the database's logger or os.Stderr.
+	Panic bool
+}
+
+// Config configures a database connection.  Rather than create a Config
+// directly, pass options such as WithTxTimeout to Connect, ConnectUnchecked,
+// or NewDB.  Settings left unset fall back to the package-level defaults,
+// e.g. TxTimeout and Confirm, so existing applications continue to work.
+type Config struct {
+	// TxTimeout configures the transaction timer for this database.  If
+	// nil, uses the global TxTimeout.
+	TxTimeout *Timeouts
+
+	// TxRegistry records the stack trace and last query of each open
+	// transaction, reported by OpenTransactions and DumpTransactions.
+	// Always on with the slow query log, or while the transaction timer is
+	// enabled.  See WithTxRegistry.
+	TxRegistry bool
+
+	// Confirm is the number of times to ping the database before a query.
+	// If nil, uses the global Confirm.
+	Confirm *int
+
+	// OnFailure is called when the database returns a connection-related
+	// error.  See DB.OnFailure.
+	OnFailure FailureFn
+
+	// Logger receives messages from Hermes.  If nil, uses the global
+	// DefaultLogger.
+	Logger Logger
+
+	// QueryLog, if not nil, logs every statement at this level.
+	QueryLog *LogLevel
+
+	// SlowQuery logs statements that take longer than this.  Zero disables
+	// the slow query log.  See WithSlowQueryLog.
+	SlowQuery time.Duration
+
+	// SlowQueryExplain is the minimum time between EXPLAINs of slow
+	// queries.  Zero disables EXPLAIN.  See WithSlowQueryExplain.
+	SlowQueryExplain time.Duration
+
+	// MaxOpen is the maximum number of open connections in the pool.  Zero
+	// leaves the pool setting unchanged.
+	MaxOpen int
+
+	// MaxIdle is the maximum number of idle connections in the pool.  Zero
+	// leaves the pool setting unchanged.
+	MaxIdle int
+
+	// MaxLifetime is the maximum time a connection may be reused.  Zero
+	// leaves the pool setting unchanged.
+	MaxLifetime time.Duration
+
+	// NestedSavepoints backs nested transactions with savepoints.  See
+	// DB.NestedSavepoints.
+	NestedSavepoints bool
+
+	// Retry is the retry policy used by InTx for this database.  If nil,
+	// uses the global Retry.
+	Retry *RetryPolicy
+
+	// Hooks are registered with the database.  See DB.AddHook.
+	Hooks []Hook
+
+	// Tracer creates spans for transactions and statements.  See
+	// WithTracer.
+	Tracer Tracer
+
+	// HealthInterval is how often the health monitor pings the database.
+	// Zero disables the health monitor.  See WithHealthCheck.
+	HealthInterval time.Duration
+
+	// HealthMaxBackoff is the longest the health monitor waits between
+	// attempts to reconnect to an unhealthy database.
+	HealthMaxBackoff time.Duration
+
+	// OnHealthChange is called when the health monitor detects the
+	// database has become healthy or unhealthy.
+	OnHealthChange HealthFn
+
+	// ReplicaLagInterval is how often a Cluster checks the lag of its
+	// replicas.  Zero disables the lag monitor.  See WithReplicaLag.
+	ReplicaLagInterval time.Duration
+
+	// MaxReplicaLag excludes replicas lagging further behind the primary
+	// from reads.  Zero doesn't exclude replicas for lag.
+	MaxReplicaLag time.Duration
+
+	// CircuitFailures is the number of consecutive connection failures
+	// that opens the circuit breaker.  Zero disables the circuit breaker.
+	// See WithCircuitBreaker.
+	CircuitFailures int
+
+	// CircuitCooldown is how long the circuit breaker stays open before
+	// pinging the database.
+	CircuitCooldown time.Duration
+
+	// OnCircuitChange is called when the circuit breaker changes state.
+	OnCircuitChange CircuitFn
+}
+
+// Applies the pool settings to the database connection pool.
+func (cfg *Config) configure(internal *sqlx.DB) {
+	if cfg.MaxOpen > 0 {
+		internal.SetMaxOpenConns(cfg.MaxOpen)
+	}
+
+	if cfg.MaxIdle > 0 {
+		internal.SetMaxIdleConns(cfg.MaxIdle)
+	}
+
+	if cfg.MaxLifetime > 0 {
+		internal.SetConnMaxLifetime(cfg.MaxLifetime)
+	}
+}
+
+// Option configures the database connection.
+type Option func(cfg *Config)
+
+// WithTxTimeout enables the transaction timer for the database, reporting
+// transactions left open longer than the duration.  If panic is true, panics
+// instead of logging a message.  See EnableTimeouts.
+func WithTxTimeout(dur time.Duration, panic bool) Option {
+	return func(cfg *Config) {
+		cfg.TxTimeout = &Timeouts{
+			Enabled:  dur > 0,
+			Duration: dur,
+			Panic:    panic,
+		}
+	}
+}
+
+// WithTxRegistry records where each transaction began and the last query it
+// ran, for OpenTransactions, DumpTransactions, and the slow query log.  Adds a
+// stack trace capture to every transaction, so leave it off in production
+// unless you're tracking down a problem.
+func WithTxRegistry() Option {
+	return func(cfg *Config) {
+		cfg.TxRegistry = true
+	}
+}
+
+// WithConfirm pings the database connection up to the number of retries
+// before each query.  See Confirm.
+func WithConfirm(retries int) Option {
+	return func(cfg *Config) {
+		cfg.Confirm = &retries
+	}
+}
+
+// WithOnFailure sets the function called when the database returns a
+// connection-related error.
+func WithOnFailure(fn FailureFn) Option {
+	return func(cfg *Config) {
+		cfg.OnFailure = fn
+	}
+}
+
+// WithLogger sends messages from Hermes to the logger, rather than the
+// DefaultLogger.
+func WithLogger(logger Logger) Option {
+	return func(cfg *Config) {
+		cfg.Logger = logger
+	}
+}
+
+// WithQueryLog logs every statement executed by the database and its
+// transactions at the given level, with the operation, query, number of
+// arguments, and duration.  Argument values aren't logged.
+func WithQueryLog(level LogLevel) Option {
+	return func(cfg *Config) {
+		cfg.QueryLog = &level
+	}
+}
+
+// WithMaxOpen sets the maximum number of open connections in the pool.
+func WithMaxOpen(n int) Option {
+	return func(cfg *Config) {
+		cfg.MaxOpen = n
+	}
+}
+
+// WithMaxIdle sets the maximum number of idle connections in the pool.
+func WithMaxIdle(n int) Option {
+	return func(cfg *Config) {
+		cfg.MaxIdle = n
+	}
+}
+
+// WithMaxLifetime sets the maximum time a connection in the pool may be
+// reused.
+func WithMaxLifetime(d time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.MaxLifetime = d
+	}
+}
+
+// WithNestedSavepoints backs nested transactions with savepoints.
+func WithNestedSavepoints() Option {
+	return func(cfg *Config) {
+		cfg.NestedSavepoints = true
+	}
+}
+
+// WithRetry sets the retry policy used by InTx for the database.
+func WithRetry(policy RetryPolicy) Option {
+	return func(cfg *Config) {
+		cfg.Retry = &policy
+	}
+}
+
+// WithHooks registers the hooks with the database.
+func WithHooks(hooks ...Hook) Option {
+	return func(cfg *Config) {
+		cfg.Hooks = append(cfg.Hooks, hooks...)
+	}
+}
+
+// Applies the options to a new configuration.
+func newConfig(opts []Option) Config {
+	var cfg Config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return cfg
+}
@@ -0,0 +1,66 @@
+package hermes_test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sbowman/hermes"
+)
+
+func TestConfigTxTimeout(t *testing.T) {
+	timeout := 100 * time.Millisecond
+
+	var buf bytes.Buffer
+
+	db, err := hermes.Connect(driver, database, 5, 1,
+		hermes.WithTxTimeout(timeout, false),
+		hermes.WithLogger(hermes.StdLogger(log.New(&buf, "", 0), hermes.LogWarn)))
+	if err != nil {
+		t.Fatalf("Failed to connect to the hermes_test database: %s", err)
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to create transaction: %s", err)
+	}
+	defer tx.Close()
+
+	// Should trip the timer...
+	time.Sleep(timeout * 2)
+
+	if !strings.Contains(buf.String(), "Transaction lifetime exceeded timeout") {
+		t.Error("Failed to timeout the transaction")
+	}
+
+	if hermes.TxTimeout.Enabled {
+		t.Error("Database option shouldn't change the global TxTimeout")
+	}
+}
+
+func TestConfigOptions(t *testing.T) {
+	var failed bool
+
+	db := hermes.NewDB("config", nil, nil,
+		hermes.WithNestedSavepoints(),
+		hermes.WithOnFailure(func(db *hermes.DB, err error) {
+			failed = true
+		}))
+
+	if !db.NestedSavepoints {
+		t.Error("Expected nested savepoints to be enabled")
+	}
+
+	if db.OnFailure == nil {
+		t.Fatal("Expected OnFailure to be set")
+	}
+
+	db.OnFailure(db, nil)
+
+	if !failed {
+		t.Error("Expected the configured OnFailure to be called")
+	}
+}
@@ -1,15 +1,28 @@
 package hermes
 
 import (
+	"errors"
 	"net"
 	"os"
 
 	"github.com/lib/pq"
 )
 
+// SQLSTATE classes that indicate the connection or server failed, rather than
+// the statement.
+var failureClasses = map[pq.ErrorClass]bool{
+	"08": true, // connection failed
+	"3D": true, // database not found
+	"53": true, // insufficient resources (disk, memory, etc.)
+	"57": true, // operator intervention
+	"58": true, // system error (external to PostgreSQL)
+	"XX": true, // internal server error
+}
+
 // FailureFn defines the template for the check function called when the
 // database action returns a connection-related error.  Useful for trapping
-// connection failures and resetting the database connection pool.
+// connection failures and resetting the database connection pool with
+// DB.Reconnect.
 type FailureFn func(db *DB, err error)
 
 // PanicOnFailure panics when the connection fails or the database server
@@ -24,10 +37,18 @@ func ExitOnFailure(db *DB, err error) {
 	os.Exit(2)
 }
 
+// ReconnectOnFailure replaces the database connection pool when the
+// connection fails, rather than restarting the application.  Concurrent
+// failures share a single reconnect.  See DB.Reconnect.
+func ReconnectOnFailure(db *DB, err error) {
+	_ = db.Reconnect()
+}
+
 // DidConnectionFail checks the error message returned from a database request
 // Used by hermes.PanicDB in several instances.  May be used by applications
 // with other connection types, or to test queries not covered by PanicDB, such
-// as scanning row results.
+// as scanning row results.  Looks through wrapped errors for the underlying
+// network or lib/pq error.
 //
 // If exit is nil, simply returns the error, skipping the check.
 func DidConnectionFail(err error) bool {
@@ -35,20 +56,19 @@ func DidConnectionFail(err error) bool {
 		return false
 	}
 
-	switch e := err.(type) {
-	case *net.OpError:
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
 		return true
+	}
 
-	case *pq.Error:
-		code := e.Code[0:2]
-		if code == "08" || // connection failed
-			code == "3D" || // database not found
-			code == "53" || // insufficient resources (disk, memory, etc.)
-			code == "57" || // operator intervention
-			code == "58" || // system error (external to PostgreSQL)
-			code == "XX" { // internal server error
-			return true
+	var e *pq.Error
+	if errors.As(err, &e) {
+		// A canceled query is the client's doing, not a server failure
+		if e.Code == "57014" {
+			return false
 		}
+
+		return failureClasses[e.Code.Class()]
 	}
 
 	return false
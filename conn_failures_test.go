@@ -3,7 +3,9 @@ package hermes_test
 import (
 	"testing"
 
+	"github.com/lib/pq"
 	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
 )
 
 // Open but don't test the connection; we want to try it with a query
@@ -68,3 +70,34 @@ func TestGetWithFailure(t *testing.T) {
 		t.Error("Failed to call the db.OnError function!")
 	}
 }
+
+func TestQueryCanceledIsNotFailure(t *testing.T) {
+	fake := hermestest.New()
+	db := fake.DB()
+	defer db.Close()
+
+	var failed bool
+
+	db.OnFailure = func(db *hermes.DB, err error) {
+		failed = true
+	}
+
+	fake.ExpectExec("update samples").WillReturnError(&pq.Error{Code: "57014"})
+
+	_, err := db.Exec("update samples set name = 'Bob'")
+	if err == nil {
+		t.Fatal("Expected the query to be canceled")
+	}
+
+	if hermes.DidConnectionFail(err) {
+		t.Errorf("Expected a canceled query not to be a connection failure: %s", err)
+	}
+
+	if failed {
+		t.Error("Expected a canceled query not to call the db.OnFailure function")
+	}
+
+	if !hermes.DidConnectionFail(&pq.Error{Code: "57P01"}) {
+		t.Error("Expected an admin shutdown to be a connection failure")
+	}
+}
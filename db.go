@@ -4,9 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"os"
 	"path/filepath"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -16,39 +16,123 @@ import (
 type DB struct {
 	// OnFailure, if defined, is called when the database connection returns
 	// a connection failed or other server-related error.  May be used to
-	// reset the database pool connections.  Optional.
+	// reset the database pool connections with Reconnect.  Optional.
 	OnFailure FailureFn
 
+	// NestedSavepoints, if true, backs nested transactions with savepoints.
+	// Beginning a nested transaction creates a savepoint, committing it
+	// releases the savepoint, and rolling it back only rolls back to the
+	// savepoint, leaving the parent transaction intact.  Defaults to false,
+	// where rolling back a nested transaction rolls back the entire
+	// transaction.
+	NestedSavepoints bool
+
+	poolMu   sync.RWMutex // guards name, internal, closed, and refs; the pool changes on reconnect
 	name     string
 	internal *sqlx.DB
+	closed   bool
+	refs     map[*sqlx.DB]*poolRefs // transactions open on each pool
+	config   Config
+	hooks    []Hook
+	txs      txRegistry
+
+	driver     string   // the driver and data source names used to connect,
+	dsns       []string // for reconnects and failover
+	reconnects reconnects
+
+	breaker *breaker // nil unless configured WithCircuitBreaker
+
+	unhealthy int32         // set by the health monitor; access atomically
+	done      chan struct{} // closed to stop the health monitor
+	stopOnce  sync.Once
 }
 
-// NewDB creates a new database connection.  Primary used for testing.
-func NewDB(name string, internal *sqlx.DB, fn FailureFn) *DB {
-	return &DB{
-		OnFailure: fn,
-		name:      name,
-		internal:  internal,
+// NewDB creates a new database connection.  Primary used for testing.  Options
+// configure the database connection; a WithOnFailure option overrides the
+// failure function.
+func NewDB(name string, internal *sqlx.DB, fn FailureFn, opts ...Option) *DB {
+	return newDB(name, internal, fn, "", nil, opts)
+}
+
+// Creates the database, with the driver and data source names to reconnect
+// with, if any.  Sets everything up before starting the health monitor, which
+// reads them from its own goroutine.
+func newDB(name string, internal *sqlx.DB, fn FailureFn, driver string, dsns []string, opts []Option) *DB {
+	cfg := newConfig(opts)
+	if cfg.OnFailure == nil {
+		cfg.OnFailure = fn
+	}
+
+	cfg.configure(internal)
+
+	db := &DB{
+		OnFailure:        cfg.OnFailure,
+		NestedSavepoints: cfg.NestedSavepoints,
+		name:             name,
+		internal:         internal,
+		config:           cfg,
+		hooks:            append([]Hook(nil), cfg.Hooks...),
+		driver:           driver,
+		dsns:             dsns,
 	}
+
+	if cfg.Tracer != nil {
+		db.hooks = append(db.hooks, tracing{cfg.Tracer})
+	}
+
+	if cfg.QueryLog != nil {
+		db.hooks = append(db.hooks, queryLog{db.logger(), *cfg.QueryLog})
+	}
+
+	if cfg.SlowQuery > 0 {
+		db.hooks = append(db.hooks, &slowLog{db: db, threshold: cfg.SlowQuery, interval: cfg.SlowQueryExplain})
+	}
+
+	if cfg.CircuitFailures > 0 {
+		db.breaker = &breaker{threshold: cfg.CircuitFailures, cooldown: cfg.CircuitCooldown}
+	}
+
+	if cfg.HealthInterval > 0 {
+		db.startMonitor(cfg.HealthInterval, cfg.HealthMaxBackoff)
+	}
+
+	return db
 }
 
 // MaxOpen sets the maximum number of database connections to pool.
 func (db *DB) MaxOpen(n int) {
-	db.raw().SetMaxOpenConns(n)
+	db.poolMu.Lock()
+	defer db.poolMu.Unlock()
+
+	db.config.MaxOpen = n
+	db.internal.SetMaxOpenConns(n)
 }
 
 // MaxIdle set the maximum number of idle connections to leave in the pool.
 func (db *DB) MaxIdle(n int) {
-	db.raw().SetMaxIdleConns(n)
+	db.poolMu.Lock()
+	defer db.poolMu.Unlock()
+
+	db.config.MaxIdle = n
+	db.internal.SetMaxIdleConns(n)
 }
 
 // Ping the database to ensure it's alive.
 func (db *DB) Ping() error {
-	return db.check(db.raw().Ping())
+	return db.check(db.base().Ping())
 }
 
-// BaseDB returns the base database connection.
+// BaseDB returns the base database connection.  After a failover, returns the
+// connection to the new primary.
 func (db *DB) BaseDB() *sqlx.DB {
+	return db.base()
+}
+
+// Returns the current connection pool.
+func (db *DB) base() *sqlx.DB {
+	db.poolMu.RLock()
+	defer db.poolMu.RUnlock()
+
 	return db.internal
 }
 
@@ -65,51 +149,123 @@ func (db *DB) Context() context.Context {
 // Begin a new transaction.  Returns a Conn wrapping the transaction
 // (*sqlx.Tx).
 func (db *DB) Begin() (Conn, error) {
-	tx, err := db.raw().Beginx()
-	if err != nil {
-		return nil, db.check(err)
-	}
-
-	return &Tx{
-		db:       db,
-		internal: tx,
-		timer:    newTxTimer(),
-	}, nil
+	return db.begin(nil, nil)
 }
 
 // BeginCtx begins a new transaction in context.  The Conn will have the context
 // associated with it and use it for all subsequent commands.
 func (db *DB) BeginCtx(ctx context.Context) (Conn, error) {
-	tx, err := db.raw().Beginx()
+	return db.begin(ctx, nil)
+}
+
+// BeginTx begins a new transaction in context with the given options.  The
+// Conn will have the context associated with it and use it for all subsequent
+// commands.  The options may be nil to use the database defaults.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (Conn, error) {
+	return db.begin(ctx, opts)
+}
+
+// Starts the database transaction.  Must be called directly from the public
+// Begin functions, so the transaction timer can locate the caller.
+func (db *DB) begin(ctx context.Context, opts *sql.TxOptions) (Conn, error) {
+	driverCtx := ctx
+	if driverCtx == nil {
+		driverCtx = context.Background()
+	}
+
+	// Check the connection, then hold the current pool until the transaction
+	// finishes, in case the pool is replaced by a reconnect
+	if _, err := db.raw(); err != nil {
+		return nil, err
+	}
+
+	pool := db.acquire()
+
+	tx, err := pool.BeginTxx(driverCtx, opts)
 	if err != nil {
+		db.release(pool)
 		return nil, db.check(err)
 	}
 
-	return &Tx{
+	record := db.track(2)
+
+	t := &Tx{
 		ctx:      ctx,
+		opts:     opts,
 		db:       db,
+		pool:     pool,
 		internal: tx,
-		timer:    newTxTimer(),
-	}, nil
+		nested:   db.NestedSavepoints,
+		record:   record,
+		timer:    newTxTimer(db.timeouts(), db.logger(), record),
+	}
+
+	db.beginTx(ctx, t)
+
+	return t, nil
 }
 
 // Exec executes a database statement with no results..
 func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
-	res, err := db.raw().Exec(query, args...)
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext executes a database statement with no results, using the
+// context to cancel the request.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	conn, err := db.raw()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := db.runExec(ctx, query, args, nil, func() (sql.Result, error) {
+		return conn.ExecContext(ctx, query, args...)
+	})
+
 	return res, db.check(err)
 }
 
 // Query the databsae.
 func (db *DB) Query(query string, args ...interface{}) (*sqlx.Rows, error) {
-	rows, err := db.raw().Queryx(query, args...)
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext queries the database, using the context to cancel the request.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	conn, err := db.raw()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows *sqlx.Rows
+
+	err = db.run(ctx, OpQuery, query, args, nil, func() (err error) {
+		rows, err = conn.QueryxContext(ctx, query, args...)
+		return err
+	})
+
 	return rows, db.check(err)
 }
 
 // Row returns the results for a single row.
 func (db *DB) Row(query string, args ...interface{}) (*sqlx.Row, error) {
-	row := db.raw().QueryRowx(query, args...)
+	return db.RowContext(context.Background(), query, args...)
+}
+
+// RowContext returns the results for a single row, using the context to
+// cancel the request.
+func (db *DB) RowContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Row, error) {
+	conn, err := db.raw()
+	if err != nil {
+		return nil, err
+	}
 
-	err := row.Err()
+	var row *sqlx.Row
+
+	err = db.run(ctx, OpRow, query, args, nil, func() error {
+		row = conn.QueryRowxContext(ctx, query, args...)
+		return row.Err()
+	})
 	if err != nil {
 		return nil, db.check(err)
 	}
@@ -119,18 +275,61 @@ func (db *DB) Row(query string, args ...interface{}) (*sqlx.Row, error) {
 
 // Prepare a database query.
 func (db *DB) Prepare(query string) (*sqlx.Stmt, error) {
-	stmt, err := db.raw().Preparex(query)
+	return db.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext prepares a database query, using the context to cancel the
+// request.
+func (db *DB) PrepareContext(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	conn, err := db.raw()
+	if err != nil {
+		return nil, err
+	}
+
+	var stmt *sqlx.Stmt
+
+	err = db.run(ctx, OpPrepare, query, nil, nil, func() (err error) {
+		stmt, err = conn.PreparexContext(ctx, query)
+		return err
+	})
+
 	return stmt, db.check(err)
 }
 
 // Get a single record from the database, e.g. "SELECT ... LIMIT 1".
 func (db *DB) Get(dest interface{}, query string, args ...interface{}) error {
-	return db.check(db.raw().Get(dest, query, args...))
+	return db.GetContext(context.Background(), dest, query, args...)
+}
+
+// GetContext gets a single record from the database, using the context to
+// cancel the request.
+func (db *DB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	conn, err := db.raw()
+	if err != nil {
+		return err
+	}
+
+	return db.check(db.run(ctx, OpGet, query, args, nil, func() error {
+		return conn.GetContext(ctx, dest, query, args...)
+	}))
 }
 
 // Select a collection of records from the database.
 func (db *DB) Select(dest interface{}, query string, args ...interface{}) error {
-	return db.check(db.raw().Select(dest, query, args...))
+	return db.SelectContext(context.Background(), dest, query, args...)
+}
+
+// SelectContext selects a collection of records from the database, using the
+// context to cancel the request.
+func (db *DB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	conn, err := db.raw()
+	if err != nil {
+		return err
+	}
+
+	return db.check(db.run(ctx, OpSelect, query, args, nil, func() error {
+		return conn.SelectContext(ctx, dest, query, args...)
+	}))
 }
 
 // Commit does nothing in a raw connection.
@@ -143,9 +342,17 @@ func (db *DB) Rollback() error {
 	return nil
 }
 
-// Close closes the database connection and returns it to the pool.
+// Close closes the database connection and returns it to the pool.  Stops the
+// health monitor, if running.
 func (db *DB) Close() error {
-	return db.check(db.raw().Close())
+	db.stopMonitor()
+
+	db.poolMu.Lock()
+	db.closed = true
+	internal := db.internal
+	db.poolMu.Unlock()
+
+	return db.check(internal.Close())
 }
 
 // RolledBack always returns false.
@@ -153,65 +360,169 @@ func (db *DB) RolledBack() bool {
 	return false
 }
 
-// Name returns the datasource name for this connection
+// Name returns the datasource name for this connection.  After a failover,
+// returns the data source name of the new primary.
 func (db *DB) Name() string {
+	db.poolMu.RLock()
+	defer db.poolMu.RUnlock()
+
 	return db.name
 }
 
-// Checks the error message and alerts if there was a problem.
+// Checks the error message and alerts if there was a problem.  Counts
+// connection failures toward the circuit breaker, if configured.  If the
+// health monitor is running, a connection failure marks the database unhealthy
+// until the monitor reconnects.  If the database was connected with several data
+// source names, a connection failure or a write to a read-only server starts
+// a failover.
 func (db *DB) check(err error) error {
-	if err == nil || db.OnFailure == nil || !DidConnectionFail(err) {
+	if err == nil {
+		db.recordResult(false)
+		return nil
+	}
+
+	if db.canFailover() && isReadOnly(err) {
+		db.logger().Log(LogWarn, "Database is read-only", "err", err)
+		go db.reconnect(false)
+
+		return err
+	}
+
+	if !DidConnectionFail(err) {
+		db.recordResult(false)
 		return err
 	}
 
-	db.OnFailure(db, err)
+	db.logger().Log(LogError, "Database connection failed", "err", err)
+	db.recordResult(true)
+
+	if db.monitoring() {
+		db.setHealthy(false)
+	}
+
+	if db.OnFailure != nil {
+		db.OnFailure(db, err)
+	}
+
+	if db.canFailover() {
+		go db.reconnect(false)
+	}
+
 	return err
 }
 
-// Returns a reference to a database connection, but tests for validity prior
-// to returning and ensures a valid database connection.  If the connection
-// fails, may panic.
-func (db *DB) raw() *sqlx.DB {
-	if Confirm == 0 {
-		return db.internal
+// Returns a reference to a database connection.  If the circuit breaker is
+// open, returns ErrCircuitOpen immediately.  If the health monitor is running
+// and the database is unhealthy, returns ErrUnavailable immediately.
+// Otherwise, if configured to confirm connections, tests for validity prior
+// to returning, and returns ErrUnavailable if the connection can't be
+// confirmed.
+func (db *DB) raw() (*sqlx.DB, error) {
+	if err := db.allow(); err != nil {
+		return nil, err
+	}
+
+	if db.monitoring() && !db.Healthy() {
+		return nil, ErrUnavailable
+	}
+
+	conn := db.base()
+
+	confirm := db.confirm()
+	if confirm == 0 {
+		return conn, nil
 	}
 
 	// Repeatedly ping the connection; ping will also try to reconnect if
 	// the connection is lost
-	conn := db.internal
-	for idx := 0; idx < Confirm; idx++ {
+	backoff := ExponentialBackoff(10*time.Millisecond, time.Second)
+
+	for idx := 0; idx < confirm; idx++ {
 		if err := conn.Ping(); err == nil {
-			return conn
+			return conn, nil
+		}
+
+		if idx < confirm-1 {
+			time.Sleep(backoff(idx + 1))
 		}
-		time.Sleep(time.Microsecond)
 	}
 
-	panic("db: connectivity lost")
+	db.recordResult(true)
+
+	return nil, ErrUnavailable
+}
+
+// Returns the transaction timer configuration for the database, falling back
+// to the global TxTimeout.
+func (db *DB) timeouts() *Timeouts {
+	if db.config.TxTimeout != nil {
+		return db.config.TxTimeout
+	}
+
+	return &TxTimeout
+}
+
+// Returns the number of times to confirm the connection before a query,
+// falling back to the global Confirm.
+func (db *DB) confirm() int {
+	if db.config.Confirm != nil {
+		return *db.config.Confirm
+	}
+
+	return Confirm
+}
+
+// Returns the logger for the database, falling back to the global
+// DefaultLogger.
+func (db *DB) logger() Logger {
+	if db.config.Logger != nil {
+		return db.config.Logger
+	}
+
+	return DefaultLogger
+}
+
+// Returns the retry policy InTx uses for the database, falling back to the
+// global Retry.
+func (db *DB) retryPolicy() RetryPolicy {
+	if db.config.Retry != nil {
+		return *db.config.Retry
+	}
+
+	return Retry
 }
 
 type txTimer struct {
-	timer *time.Timer
-	file  string // track where the transaction was declared
-	line  int
+	timer   *time.Timer
+	timeout *Timeouts
+	logger  Logger
+	record  *txRecord // reported with the timeout
+	file    string    // track where the transaction was declared
+	line    int
 }
 
 // Helper function to configure a transaction timer.  Transaction timers report
-// an error if a transaction is left open longer than TxTimeout.
-func newTxTimer() *txTimer {
-	if !TxTimeout.Enabled || TxTimeout.Duration == 0 {
+// an error if a transaction is left open longer than the timeout, along with
+// the transaction's details from the registry.
+func newTxTimer(timeout *Timeouts, logger Logger, record *txRecord) *txTimer {
+	if !timeout.Enabled || timeout.Duration == 0 {
 		return nil
 	}
 
-	var t txTimer
+	t := txTimer{
+		timeout: timeout,
+		logger:  logger,
+		record:  record,
+	}
 
-	_, file, line, ok := runtime.Caller(2)
+	_, file, line, ok := runtime.Caller(3)
 	if ok {
 		t.file = fmt.Sprintf("%s/%s", filepath.Base(filepath.Dir(file)), filepath.Base(file))
 		t.line = line
 
 	}
 
-	t.timer = time.AfterFunc(TxTimeout.Duration, t.txTimedOut)
+	t.timer = time.AfterFunc(timeout.Duration, t.txTimedOut)
 
 	return &t
 }
@@ -227,7 +538,7 @@ func (t *txTimer) stop() {
 
 // Called if the transaction timer trips, i.e. the transaction exceeded its timeout.
 func (t *txTimer) txTimedOut() {
-	if !TxTimeout.Enabled {
+	if !t.timeout.Enabled {
 		return
 	}
 
@@ -238,9 +549,25 @@ func (t *txTimer) txTimedOut() {
 		msg = "Transaction lifetime exceeded timeout"
 	}
 
-	if TxTimeout.Panic {
+	if t.timeout.Panic {
+		if t.record != nil {
+			msg = fmt.Sprintf("%s\n%s", msg, t.record.info(time.Now()))
+		}
+
 		panic(msg)
 	}
 
-	fmt.Fprintln(os.Stderr, msg)
+	var fields []interface{}
+	if t.record != nil {
+		info := t.record.info(time.Now())
+		fields = []interface{}{
+			"tx", info.ID,
+			"age", info.Age,
+			"depth", info.Depth,
+			"last_query", info.LastQuery,
+			"stack", info.Stack,
+		}
+	}
+
+	t.logger.Log(LogWarn, msg, fields...)
 }
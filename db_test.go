@@ -1,6 +1,10 @@
 package hermes_test
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"testing"
+)
 
 func TestExec(t *testing.T) {
 	db := connect(t)
@@ -48,6 +52,27 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestSelectContext(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	var names []string
+	if err := db.SelectContext(context.Background(), &names, "select 'Bob' union select 'Frank'"); err != nil {
+		t.Errorf("Unable to select names: %s", err)
+	}
+
+	if len(names) != 2 {
+		t.Errorf("Expected two names; got %d", len(names))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := db.SelectContext(ctx, &names, "select 'Bob'"); !errors.Is(err, context.Canceled) {
+		t.Errorf(`Expected error "%s"; got "%v"`, context.Canceled, err)
+	}
+}
+
 func TestQuery(t *testing.T) {
 	// TODO
 }
@@ -0,0 +1,72 @@
+package hermes
+
+import (
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrNoPrimary returned when none of the data sources is a writable primary
+// database.
+var ErrNoPrimary = errors.New("no primary database available")
+
+// Returns true if the server is a replica, i.e. in recovery.
+const recoveryQuery = "SELECT pg_is_in_recovery()"
+
+// ConnectFailover connects to the writable primary among the data source
+// names, e.g. a primary and its standbys.  Each data source is tried in order,
+// and the first that isn't in recovery (pg_is_in_recovery()) becomes the
+// database connection.  Returns ErrNoPrimary if none of them is a primary.
+//
+// When a statement fails because the connection failed, or because the
+// server has become read-only, Hermes looks for the primary again in the
+// background and swaps the connection pool over to it.  Statements that failed
+// aren't retried, but subsequent statements go to the new primary, without
+// restarting the application.  Set connect_timeout in the data source names,
+// so an unreachable server doesn't hold up the search.
+func ConnectFailover(driverName string, dataSourceNames []string, maxOpen, maxIdle int, opts ...Option) (*DB, error) {
+	opts = append([]Option{WithMaxOpen(maxOpen), WithMaxIdle(maxIdle)}, opts...)
+	cfg := newConfig(opts)
+
+	internal, dsn, err := findPrimary(driverName, dataSourceNames, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDB(dsn, internal, nil, driverName, dataSourceNames, opts), nil
+}
+
+// Returns a connection pool for the first data source that's a writable
+// primary, along with its data source name.
+func findPrimary(driverName string, dataSourceNames []string, cfg *Config) (*sqlx.DB, string, error) {
+	for _, dsn := range dataSourceNames {
+		internal, err := dial(driverName, dsn, cfg.MaxOpen, cfg.MaxIdle)
+		if err != nil {
+			return nil, "", err // only configuration errors
+		}
+
+		var recovering bool
+		if err := internal.QueryRowx(recoveryQuery).Scan(&recovering); err != nil || recovering {
+			_ = internal.Close()
+			continue
+		}
+
+		cfg.configure(internal)
+		return internal, dsn, nil
+	}
+
+	return nil, "", ErrNoPrimary
+}
+
+// Can the database fail over to another server?
+func (db *DB) canFailover() bool {
+	return len(db.dsns) > 1
+}
+
+// Did the statement fail because the server is read-only, e.g. a primary
+// that's been demoted to a standby?
+func isReadOnly(err error) bool {
+	var e *pq.Error
+	return errors.As(err, &e) && e.Code == "25006"
+}
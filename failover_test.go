@@ -0,0 +1,104 @@
+package hermes_test
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+// Returns the rows for a pg_is_in_recovery() query.
+func recoveryRows(recovering bool) *hermestest.Rows {
+	return hermestest.NewRows("pg_is_in_recovery").AddRow(recovering)
+}
+
+func TestConnectFailover(t *testing.T) {
+	standby, primary := hermestest.New(), hermestest.New()
+
+	standby.ExpectQuery("pg_is_in_recovery").WillReturnRows(recoveryRows(true))
+	primary.ExpectQuery("pg_is_in_recovery").WillReturnRows(recoveryRows(false))
+
+	db, err := hermes.ConnectFailover(hermestest.DriverName, []string{standby.DataSourceName(), primary.DataSourceName()}, 5, 1)
+	if err != nil {
+		t.Fatalf("Unable to connect: %s", err)
+	}
+	defer db.Close()
+
+	if db.Name() != primary.DataSourceName() {
+		t.Errorf("Expected to connect to the primary; connected to %s", db.Name())
+	}
+
+	for _, mock := range []*hermestest.Mock{standby, primary} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestConnectFailoverNoPrimary(t *testing.T) {
+	standby1, standby2 := hermestest.New(), hermestest.New()
+
+	standby1.ExpectQuery("pg_is_in_recovery").WillReturnRows(recoveryRows(true))
+	standby2.ExpectQuery("pg_is_in_recovery").WillReturnRows(recoveryRows(true))
+
+	if _, err := hermes.ConnectFailover(hermestest.DriverName, []string{standby1.DataSourceName(), standby2.DataSourceName()}, 5, 1); err != hermes.ErrNoPrimary {
+		t.Errorf(`Expected error "%s"; got "%v"`, hermes.ErrNoPrimary, err)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"connection failure", &pq.Error{Code: "08006"}},
+		{"read only", &pq.Error{Code: "25006"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			old, promoted := hermestest.New(), hermestest.New()
+
+			old.ExpectQuery("pg_is_in_recovery").WillReturnRows(recoveryRows(false))
+
+			db, err := hermes.ConnectFailover(hermestest.DriverName, []string{old.DataSourceName(), promoted.DataSourceName()}, 5, 1,
+				hermes.WithLogger(hermes.StdLogger(log.New(ioutil.Discard, "", 0), hermes.LogError)))
+			if err != nil {
+				t.Fatalf("Unable to connect: %s", err)
+			}
+			defer db.Close()
+
+			old.ExpectExec("update samples").WillReturnError(test.err)
+			old.ExpectQuery("pg_is_in_recovery").WillReturnRows(recoveryRows(true))
+			promoted.ExpectQuery("pg_is_in_recovery").WillReturnRows(recoveryRows(false))
+			promoted.ExpectExec("update samples")
+
+			if _, err := db.Exec("update samples set name = 'Bob'"); err == nil {
+				t.Fatal("Expected the update to fail")
+			}
+
+			deadline := time.Now().Add(time.Second)
+			for db.Name() != promoted.DataSourceName() {
+				if time.Now().After(deadline) {
+					t.Fatal("Failed to fail over to the promoted database")
+				}
+
+				time.Sleep(time.Millisecond)
+			}
+
+			if _, err := db.Exec("update samples set name = 'Bob'"); err != nil {
+				t.Fatalf("Unable to update the promoted database: %s", err)
+			}
+
+			for _, mock := range []*hermestest.Mock{old, promoted} {
+				if err := mock.ExpectationsWereMet(); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
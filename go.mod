@@ -1,10 +1,11 @@
 module github.com/sbowman/hermes
 
-go 1.13
+go 1.14
 
 require (
 	github.com/google/uuid v1.1.1
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/lib/pq v1.2.0
 	google.golang.org/appengine v1.6.4 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,138 @@
+package hermes
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrUnavailable returned when the database can't be reached, i.e. the health
+// monitor has marked the database unhealthy, or the connection couldn't be
+// confirmed before a query.
+var ErrUnavailable = errors.New("database unavailable")
+
+// HealthFn is called when the health monitor detects the database has become
+// healthy or unhealthy.
+type HealthFn func(db *DB, healthy bool)
+
+// WithHealthCheck runs a background health monitor that pings the database on
+// the interval.  If a ping fails, the database is marked unhealthy and queries
+// fail immediately with ErrUnavailable, rather than waiting on the connection.
+// While unhealthy, the monitor tries to reconnect, backing off exponentially
+// up to the max backoff.  If max backoff is zero, defaults to ten times the
+// interval.
+func WithHealthCheck(interval, maxBackoff time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.HealthInterval = interval
+		cfg.HealthMaxBackoff = maxBackoff
+	}
+}
+
+// WithHealthChange sets the function called when the health monitor detects
+// the database has become healthy or unhealthy.
+func WithHealthChange(fn HealthFn) Option {
+	return func(cfg *Config) {
+		cfg.OnHealthChange = fn
+	}
+}
+
+// Healthy returns false if the health monitor has found the database to be
+// unavailable.  Always returns true if the health monitor isn't running.
+func (db *DB) Healthy() bool {
+	return atomic.LoadInt32(&db.unhealthy) == 0
+}
+
+// Starts the background health monitor.
+func (db *DB) startMonitor(interval, maxBackoff time.Duration) {
+	if maxBackoff < interval {
+		maxBackoff = 10 * interval
+	}
+
+	db.done = make(chan struct{})
+	go db.monitor(interval, ExponentialBackoff(interval, maxBackoff))
+}
+
+// Stops the background health monitor, if it's running.
+func (db *DB) stopMonitor() {
+	if db.done == nil {
+		return
+	}
+
+	db.stopOnce.Do(func() {
+		close(db.done)
+	})
+}
+
+// Is the health monitor running?
+func (db *DB) monitoring() bool {
+	return db.done != nil
+}
+
+// Pings the database on the interval while it's healthy.  Once it becomes
+// unhealthy, pings with an exponential backoff until it recovers.  If the
+// database can fail over, looks for the primary after each failed ping.
+func (db *DB) monitor(interval time.Duration, backoff func(retry int) time.Duration) {
+	var failures int
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-db.done:
+			return
+		case <-timer.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := db.base().PingContext(ctx)
+		cancel()
+
+		if err == nil {
+			failures = 0
+			db.setHealthy(true)
+			timer.Reset(interval)
+			continue
+		}
+
+		failures++
+		db.setHealthy(false)
+
+		// The primary may have moved; if it has, check the new one now,
+		// otherwise keep backing off
+		if db.canFailover() {
+			if moved, _ := db.reconnect(false); moved {
+				timer.Reset(0)
+				continue
+			}
+		}
+
+		timer.Reset(backoff(failures))
+	}
+}
+
+// Updates the health of the database, calling the OnHealthChange function if
+// the health changed.
+func (db *DB) setHealthy(healthy bool) {
+	var changed bool
+	if healthy {
+		changed = atomic.CompareAndSwapInt32(&db.unhealthy, 1, 0)
+	} else {
+		changed = atomic.CompareAndSwapInt32(&db.unhealthy, 0, 1)
+	}
+
+	if !changed {
+		return
+	}
+
+	if healthy {
+		db.logger().Log(LogInfo, "Database is healthy")
+	} else {
+		db.logger().Log(LogWarn, "Database is unhealthy")
+	}
+
+	if db.config.OnHealthChange != nil {
+		db.config.OnHealthChange(db, healthy)
+	}
+}
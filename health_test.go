@@ -0,0 +1,65 @@
+package hermes_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sbowman/hermes"
+)
+
+func TestHealthCheck(t *testing.T) {
+	changed := make(chan bool, 1)
+
+	db, err := hermes.ConnectUnchecked(driver, "postgres://postgres@127.0.0.1/nemo?sslmode=disable&connect_timeout=10", 5, 1,
+		hermes.WithHealthCheck(10*time.Millisecond, 50*time.Millisecond),
+		hermes.WithHealthChange(func(db *hermes.DB, healthy bool) {
+			changed <- healthy
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if !db.Healthy() {
+		t.Error("Expected the database to start out healthy")
+	}
+
+	select {
+	case healthy := <-changed:
+		if healthy {
+			t.Error("Expected the database to become unhealthy")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Health monitor failed to detect the missing database")
+	}
+
+	if db.Healthy() {
+		t.Error("Expected the database to be unhealthy")
+	}
+
+	if _, err := db.Exec("select 1"); err != hermes.ErrUnavailable {
+		t.Errorf(`Expected error "%s"; got "%v"`, hermes.ErrUnavailable, err)
+	}
+
+	if _, err := db.Begin(); err != hermes.ErrUnavailable {
+		t.Errorf(`Expected error "%s"; got "%v"`, hermes.ErrUnavailable, err)
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	db, err := hermes.Connect(driver, database, 5, 1, hermes.WithHealthCheck(10*time.Millisecond, 0))
+	if err != nil {
+		t.Fatalf("Failed to connect to the hermes_test database: %s", err)
+	}
+	defer db.Close()
+
+	time.Sleep(50 * time.Millisecond)
+
+	if !db.Healthy() {
+		t.Error("Expected the database to be healthy")
+	}
+
+	if _, err := db.Exec("select 1"); err != nil {
+		t.Errorf("Unable to exec: %s", err)
+	}
+}
@@ -31,19 +31,10 @@ var (
 	// Enabling transaction timeouts should not be used in production.  If
 	// enabled, a timer is created for each transaction, adding measurable
 	// overhead to database processing.
-	TxTimeout struct {
-		// Enabled must be set to true to enable transaction timers.
-		Enabled bool
-
-		// Duration is the time to wait in milliseconds before reporting
-		// a transaction being left open.
-		Duration time.Duration
-
-		// Panic set to true causes Hermes to panic if the transaction
-		// remains open past its duration.  When false, Hermes simply
-		// writes a message to os.Stderr.
-		Panic bool
-	}
+	//
+	// TxTimeout is the default for databases not configured with the
+	// WithTxTimeout option.
+	TxTimeout Timeouts
 
 	// ErrTooManyClients matches the error returned by PostgreSQL when the
 	// number of client connections exceeds that allowed by the server.
@@ -53,6 +44,9 @@ var (
 	// for validity before attempting a query, i.e. issue a Ping first.
 	// Set to the number of retries before failing.  Default is to not
 	// confirm the connection, i.e. zero retries.
+	//
+	// Confirm is the default for databases not configured with the
+	// WithConfirm option.
 	Confirm int
 )
 
@@ -77,24 +71,54 @@ type Conn interface {
 	// associated with it and use it for all subsequent commands.
 	BeginCtx(ctx context.Context) (Conn, error)
 
+	// BeginTx begins a new transaction in context with the given options,
+	// such as the isolation level or a read-only transaction.  A nested
+	// transaction may not request options its parent transaction can't
+	// honor.
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (Conn, error)
+
 	// Exec executes a database statement with no results..
 	Exec(query string, args ...interface{}) (sql.Result, error)
 
+	// ExecContext executes a database statement with no results, using the
+	// context to cancel the request.
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+
 	// Query the databsae.
 	Query(query string, args ...interface{}) (*sqlx.Rows, error)
 
+	// QueryContext queries the database, using the context to cancel the
+	// request.
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+
 	// Row queries for a single row.
 	Row(query string, args ...interface{}) (*sqlx.Row, error)
 
+	// RowContext queries for a single row, using the context to cancel the
+	// request.
+	RowContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Row, error)
+
 	// Prepare a database query.
 	Prepare(query string) (*sqlx.Stmt, error)
 
+	// PrepareContext prepares a database query, using the context to cancel
+	// the request.
+	PrepareContext(ctx context.Context, query string) (*sqlx.Stmt, error)
+
 	// Get a single record from the database, e.g. "SELECT ... LIMIT 1".
 	Get(dest interface{}, query string, args ...interface{}) error
 
+	// GetContext gets a single record from the database, using the context
+	// to cancel the request.
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+
 	// Select a collection of results.
 	Select(dest interface{}, query string, args ...interface{}) error
 
+	// SelectContext selects a collection of results, using the context to
+	// cancel the request.
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+
 	// Commit the transaction.
 	Commit() error
 
@@ -106,6 +130,15 @@ type Conn interface {
 	// it hasn't been committed.  Useful in a defer.
 	Close() error
 
+	// OnCommit registers a function to call once the transaction commits.
+	// In a nested transaction, waits for the outermost transaction to
+	// commit.  On a database connection calls the function immediately.
+	OnCommit(fn func())
+
+	// OnRollback registers a function to call once the transaction rolls
+	// back.  On a database connection does nothing.
+	OnRollback(fn func())
+
 	// Is this connection in a rollback state?
 	RolledBack() bool
 
@@ -116,29 +149,47 @@ type Conn interface {
 	// connection does nothing.
 	Savepoint() (string, error)
 
+	// NamedSavepoint creates a savepoint with the given name in a
+	// transaction.  The name must be a valid SQL identifier.  On a database
+	// connection only validates the name.
+	NamedSavepoint(name string) error
+
 	// RollbackTo a savepoint ID.  On a database connection does nothing.
 	RollbackTo(savepointID string) error
+
+	// ReleaseSavepoint releases a savepoint ID, keeping the work done since
+	// the savepoint.  On a database connection does nothing.
+	ReleaseSavepoint(savepointID string) error
 }
 
-// Connect opens a connection to the database and pings it.
-func Connect(driverName, dataSourceName string, maxOpen, maxIdle int) (*DB, error) {
+// Connect opens a connection to the database and pings it.  Options configure
+// the database connection, e.g. WithTxTimeout.
+func Connect(driverName, dataSourceName string, maxOpen, maxIdle int, opts ...Option) (*DB, error) {
 	db, err := open(driverName, dataSourceName, maxOpen, maxIdle)
 	if err != nil {
 		return nil, err // should only return a misconfiguration error
 	}
 
-	return NewDB(dataSourceName, db, nil), nil
+	return connected(driverName, dataSourceName, db, maxOpen, maxIdle, opts), nil
 }
 
 // ConnectUnchecked connects to the database, but does not test the connection
-// before returning.
-func ConnectUnchecked(driverName, dataSourceName string, maxOpen, maxIdle int) (*DB, error) {
+// before returning.  Options configure the database connection, e.g.
+// WithTxTimeout.
+func ConnectUnchecked(driverName, dataSourceName string, maxOpen, maxIdle int, opts ...Option) (*DB, error) {
 	db, err := dial(driverName, dataSourceName, maxOpen, maxIdle)
 	if err != nil {
 		return nil, err // should only return a misconfiguration error
 	}
 
-	return NewDB(dataSourceName, db, nil), nil
+	return connected(driverName, dataSourceName, db, maxOpen, maxIdle, opts), nil
+}
+
+// Creates the database connection, remembering the driver, data source, and
+// pool size so it can reconnect.
+func connected(driverName, dataSourceName string, internal *sqlx.DB, maxOpen, maxIdle int, opts []Option) *DB {
+	opts = append([]Option{WithMaxOpen(maxOpen), WithMaxIdle(maxIdle)}, opts...)
+	return newDB(dataSourceName, internal, nil, driverName, []string{dataSourceName}, opts)
 }
 
 // EnableTimeouts enables the transaction timer, which will display an error
@@ -147,7 +198,8 @@ func ConnectUnchecked(driverName, dataSourceName string, maxOpen, maxIdle int) (
 // cleaned up.
 //
 // Transaction timers may be enabled and disabled at will without requiring a
-// restart.
+// restart.  Sets the default for all databases; to enable the timer for a
+// single database, use the WithTxTimeout option.
 //
 // Do not use in production!  The overhead will measurably slow down your application.
 func EnableTimeouts(dur time.Duration, panic bool) {
@@ -0,0 +1,218 @@
+package hermestest
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Implements driver.Driver, opening connections to the mock registered under
+// the data source name.
+type mockDriver struct{}
+
+func (mockDriver) Open(dsn string) (driver.Conn, error) {
+	mocksMu.Lock()
+	defer mocksMu.Unlock()
+
+	m, ok := mocks[dsn]
+	if !ok {
+		return nil, fmt.Errorf("hermestest: no mock named %s", dsn)
+	}
+
+	return &conn{mock: m}, nil
+}
+
+// A connection to the mock.  Every connection shares the mock's
+// expectations.
+type conn struct {
+	mock *Mock
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	e, err := c.mock.next(_prepare, query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := wait(ctx, e); err != nil {
+		return nil, err
+	}
+
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	e, err := c.mock.next(_begin, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.opts != nil && *e.opts != opts {
+		return nil, fmt.Errorf("hermestest: expected transaction options %+v; got %+v", *e.opts, opts)
+	}
+
+	if err := wait(ctx, e); err != nil {
+		return nil, err
+	}
+
+	return &tx{conn: c}, nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.mock.next(_exec, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := wait(ctx, e); err != nil {
+		return nil, err
+	}
+
+	if e.result == nil {
+		return driver.ResultNoRows, nil
+	}
+
+	return e.result, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.mock.next(_query, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := wait(ctx, e); err != nil {
+		return nil, err
+	}
+
+	if e.rows == nil {
+		return &rows{}, nil
+	}
+
+	return &rows{columns: e.rows.columns, values: e.rows.values}, nil
+}
+
+// A transaction on the mock.
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	e, err := t.conn.mock.next(_commit, "", nil)
+	if err != nil {
+		return err
+	}
+
+	return e.err
+}
+
+func (t *tx) Rollback() error {
+	e, err := t.conn.mock.next(_rollback, "", nil)
+	if err != nil {
+		return err
+	}
+
+	return e.err
+}
+
+// A prepared statement.  Executing the statement must meet an Exec or Query
+// expectation.
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), named(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), named(args))
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+// Iterates over the rows returned by a query.
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	pos     int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.values) {
+		return io.EOF
+	}
+
+	copy(dest, r.values[r.pos])
+	r.pos++
+
+	return nil
+}
+
+// Waits out the expectation's delay, then returns its error.  Returns the
+// context error if the context is done first.
+func wait(ctx context.Context, e *Expectation) error {
+	if e.delay > 0 {
+		timer := time.NewTimer(e.delay)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return e.err
+}
+
+// Converts positional driver values to named values.
+func named(args []driver.Value) []driver.NamedValue {
+	values := make([]driver.NamedValue, len(args))
+	for idx, arg := range args {
+		values[idx] = driver.NamedValue{Ordinal: idx + 1, Value: arg}
+	}
+
+	return values
+}
@@ -0,0 +1,384 @@
+package hermestest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/sbowman/hermes"
+	"gopkg.in/yaml.v2"
+)
+
+// KeyColumn labels a fixture row, so other rows and the test may refer to
+// it.  It isn't inserted into the table.
+const KeyColumn = "_key"
+
+var (
+	// ErrFixtureCycle returned if the references between fixture tables
+	// form a cycle, so there's no order to insert them in.
+	ErrFixtureCycle = errors.New("fixture references form a cycle")
+
+	// ErrFixtureReference returned if a fixture refers to a table, row, or
+	// column that wasn't loaded.
+	ErrFixtureReference = errors.New("invalid fixture reference")
+)
+
+// References look like "$table.key.column"; the table may include a schema.
+// Use "$$" to start a string value with a literal "$".
+var reference = regexp.MustCompile(`^\$([\w.]+)\.(\w+)\.(\w+)$`)
+
+// FixtureRow is a row of fixture data, column name to value.
+type FixtureRow map[string]interface{}
+
+// FixtureSet maps table names to the rows to insert into each table.  Rows are
+// inserted in the order they are listed.
+type FixtureSet map[string][]FixtureRow
+
+// Fixtures are the rows inserted into the database, as returned by the
+// database, so generated columns such as serial IDs are available to the test.
+type Fixtures struct {
+	tables map[string]map[string]FixtureRow
+}
+
+// LoadFixtures reads the fixture files and inserts their rows into the
+// database.  Files ending in .json are parsed as JSON; .yml and .yaml files as
+// YAML.  Each file maps table names to a list of rows:
+//
+//	authors:
+//	  - _key: bob
+//	    name: Bob
+//	books:
+//	  - title: Hermes at Sea
+//	    author_id: $authors.bob.id
+//
+// Each row may be labeled with a "_key"; unlabeled rows are keyed by their
+// position in the list, starting at "0".  A string value of the form
+// "$table.key.column" refers to a column of another fixture row, as returned
+// by the database after the row was inserted, e.g. a generated ID to use as a
+// foreign key.  Tables are inserted in dependency order.  Rows may refer to
+// earlier rows in the same table.
+//
+// Typically conn is a transaction, such as one from Tx, so the fixtures are
+// rolled back when the test completes.
+func LoadFixtures(ctx context.Context, conn hermes.Conn, paths ...string) (*Fixtures, error) {
+	set := make(FixtureSet)
+
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var fs FixtureSet
+		switch ext := strings.ToLower(filepath.Ext(path)); ext {
+		case ".json":
+			fs, err = ParseJSONFixtures(data)
+		case ".yml", ".yaml":
+			fs, err = ParseYAMLFixtures(data)
+		default:
+			err = fmt.Errorf("unsupported fixture format %q", ext)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		for table, rows := range fs {
+			set[table] = append(set[table], rows...)
+		}
+	}
+
+	return set.Load(ctx, conn)
+}
+
+// ParseJSONFixtures parses a JSON object of table names to lists of rows.
+func ParseJSONFixtures(data []byte) (FixtureSet, error) {
+	var set FixtureSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+// ParseYAMLFixtures parses a YAML map of table names to lists of rows.
+func ParseYAMLFixtures(data []byte) (FixtureSet, error) {
+	var raw map[string][]map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	set := make(FixtureSet)
+	for table, rows := range raw {
+		for _, row := range rows {
+			fr := make(FixtureRow)
+			for column, value := range row {
+				fr[column] = normalize(value)
+			}
+
+			set[table] = append(set[table], fr)
+		}
+	}
+
+	return set, nil
+}
+
+// Load inserts the fixture rows into the database in dependency order,
+// resolving references between rows.  See LoadFixtures.
+func (set FixtureSet) Load(ctx context.Context, conn hermes.Conn) (*Fixtures, error) {
+	order, err := set.order()
+	if err != nil {
+		return nil, err
+	}
+
+	fixtures := &Fixtures{tables: make(map[string]map[string]FixtureRow)}
+
+	for _, table := range order {
+		fixtures.tables[table] = make(map[string]FixtureRow)
+
+		for idx, row := range set[table] {
+			key := strconv.Itoa(idx)
+			if label, ok := row[KeyColumn]; ok {
+				key = fmt.Sprint(label)
+			}
+
+			if _, ok := fixtures.tables[table][key]; ok {
+				return nil, fmt.Errorf("duplicate fixture key %s.%s", table, key)
+			}
+
+			inserted, err := fixtures.insert(ctx, conn, table, row)
+			if err != nil {
+				return nil, fmt.Errorf("fixture %s.%s: %w", table, key, err)
+			}
+
+			fixtures.tables[table][key] = inserted
+		}
+	}
+
+	return fixtures, nil
+}
+
+// Row returns the inserted row with the given key, or nil if there isn't one.
+func (f *Fixtures) Row(table, key string) FixtureRow {
+	return f.tables[table][key]
+}
+
+// Value returns a column from an inserted row, or nil if there isn't one.
+// Text values are returned as strings.
+func (f *Fixtures) Value(table, key, column string) interface{} {
+	return f.tables[table][key][column]
+}
+
+// Inserts the row, returning the row as stored in the database.
+func (f *Fixtures) insert(ctx context.Context, conn hermes.Conn, table string, row FixtureRow) (FixtureRow, error) {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		if column != KeyColumn {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+
+	quoted := make([]string, len(columns))
+	params := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+
+	for idx, column := range columns {
+		value, err := f.resolve(row[column])
+		if err != nil {
+			return nil, err
+		}
+
+		quoted[idx] = pq.QuoteIdentifier(column)
+		params[idx] = fmt.Sprintf("$%d", idx+1)
+		args[idx] = value
+	}
+
+	var query string
+	if len(columns) == 0 {
+		query = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES RETURNING *", quoteTable(table))
+	} else {
+		query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING *", quoteTable(table),
+			strings.Join(quoted, ", "), strings.Join(params, ", "))
+	}
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inserted := make(FixtureRow)
+	if rows.Next() {
+		if err := rows.MapScan(inserted); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for column, value := range inserted {
+		if b, ok := value.([]byte); ok {
+			inserted[column] = string(b)
+		}
+	}
+
+	return inserted, nil
+}
+
+// Replaces a reference with the value of the referenced column.  Other
+// values are returned as is, with nested maps and lists encoded as JSON.
+func (f *Fixtures) resolve(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if strings.HasPrefix(v, "$$") {
+			return v[1:], nil
+		}
+
+		match := reference.FindStringSubmatch(v)
+		if match == nil {
+			return v, nil
+		}
+
+		row, ok := f.tables[match[1]][match[2]]
+		if !ok {
+			return nil, fmt.Errorf("%w: no row %s.%s", ErrFixtureReference, match[1], match[2])
+		}
+
+		column, ok := row[match[3]]
+		if !ok {
+			return nil, fmt.Errorf("%w: no column %s in %s.%s", ErrFixtureReference, match[3], match[1], match[2])
+		}
+
+		return column, nil
+
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		return string(data), nil
+	}
+
+	return value, nil
+}
+
+// Returns the tables in the order they should be inserted, so referenced rows
+// are inserted before the rows that refer to them.
+func (set FixtureSet) order() ([]string, error) {
+	tables := make([]string, 0, len(set))
+	for table := range set {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	state := make(map[string]int)
+	order := make([]string, 0, len(tables))
+
+	var visit func(table string) error
+	visit = func(table string) error {
+		switch state[table] {
+		case visiting:
+			return fmt.Errorf("%w: %s", ErrFixtureCycle, table)
+		case visited:
+			return nil
+		}
+
+		state[table] = visiting
+
+		for _, dep := range set.dependencies(table) {
+			if _, ok := set[dep]; !ok {
+				return fmt.Errorf("%w: %s refers to missing table %s", ErrFixtureReference, table, dep)
+			}
+
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+
+		state[table] = visited
+		order = append(order, table)
+
+		return nil
+	}
+
+	for _, table := range tables {
+		if err := visit(table); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// Returns the other tables the table's rows refer to, sorted.
+func (set FixtureSet) dependencies(table string) []string {
+	deps := make(map[string]bool)
+	for _, row := range set[table] {
+		for _, value := range row {
+			s, ok := value.(string)
+			if !ok {
+				continue
+			}
+
+			if match := reference.FindStringSubmatch(s); match != nil && match[1] != table {
+				deps[match[1]] = true
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(deps))
+	for dep := range deps {
+		sorted = append(sorted, dep)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// Quotes a table name, which may include the schema.
+func quoteTable(table string) string {
+	parts := strings.Split(table, ".")
+	for idx, part := range parts {
+		parts[idx] = pq.QuoteIdentifier(part)
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// Converts the maps YAML decodes nested objects into to string-keyed maps, so
+// they may be encoded as JSON.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[fmt.Sprint(key)] = normalize(val)
+		}
+		return m
+
+	case []interface{}:
+		for idx, val := range v {
+			v[idx] = normalize(val)
+		}
+		return v
+	}
+
+	return value
+}
@@ -0,0 +1,76 @@
+package hermestest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sbowman/hermes/hermestest"
+)
+
+func TestLoadFixtures(t *testing.T) {
+	mock := hermestest.New()
+	mock.Matcher = hermestest.MatchExact
+
+	db := mock.DB()
+	defer db.Close()
+
+	mock.ExpectQuery(`INSERT INTO "authors" ("name") VALUES ($1) RETURNING *`).
+		WithArgs("Bob").
+		WillReturnRows(hermestest.NewRows("id", "name").AddRow(12, "Bob"))
+	mock.ExpectQuery(`INSERT INTO "books" ("author_id", "tags", "title") VALUES ($1, $2, $3) RETURNING *`).
+		WithArgs(12, `["adventure","nautical"]`, "Hermes at Sea").
+		WillReturnRows(hermestest.NewRows("id", "author_id", "title").AddRow(1, 12, []byte("Hermes at Sea")))
+	mock.ExpectQuery(`INSERT INTO "publishers" ("name") VALUES ($1) RETURNING *`).
+		WithArgs("$Money Press").
+		WillReturnRows(hermestest.NewRows("id", "name").AddRow(3, "$Money Press"))
+
+	fixtures, err := hermestest.LoadFixtures(context.Background(), db, "testdata/authors.yml", "testdata/publishers.json")
+	if err != nil {
+		t.Fatalf("Unable to load fixtures: %s", err)
+	}
+
+	if id := fixtures.Value("authors", "bob", "id"); id != int64(12) {
+		t.Errorf("Expected Bob's ID to be 12; got %v", id)
+	}
+
+	if title := fixtures.Value("books", "sea", "title"); title != "Hermes at Sea" {
+		t.Errorf("Expected the title as a string; got %#v", title)
+	}
+
+	if row := fixtures.Row("publishers", "0"); row == nil {
+		t.Error("Expected the unlabeled publisher to be keyed by position")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFixtureCycle(t *testing.T) {
+	set, err := hermestest.ParseJSONFixtures([]byte(`{
+		"a": [{"_key": "x", "b_id": "$b.y.id"}],
+		"b": [{"_key": "y", "a_id": "$a.x.id"}]
+	}`))
+	if err != nil {
+		t.Fatalf("Unable to parse fixtures: %s", err)
+	}
+
+	if _, err := set.Load(context.Background(), nil); !errors.Is(err, hermestest.ErrFixtureCycle) {
+		t.Errorf("Expected ErrFixtureCycle; got %v", err)
+	}
+}
+
+func TestFixtureMissingTable(t *testing.T) {
+	set, err := hermestest.ParseYAMLFixtures([]byte(`
+books:
+  - author_id: $authors.bob.id
+`))
+	if err != nil {
+		t.Fatalf("Unable to parse fixtures: %s", err)
+	}
+
+	if _, err := set.Load(context.Background(), nil); !errors.Is(err, hermestest.ErrFixtureReference) {
+		t.Errorf("Expected ErrFixtureReference; got %v", err)
+	}
+}
@@ -0,0 +1,438 @@
+// Package hermestest provides helpers for testing code built on hermes.Conn.
+//
+// The Mock registers a scriptable, in-memory database/sql driver, so unit
+// tests don't need a live PostgreSQL database.  Declare the statements the
+// code under test should execute, in order, along with the results, rows, or
+// errors to return, then pass the mock's *hermes.DB to the code under test:
+//
+//	mock := hermestest.New()
+//	db := mock.DB()
+//	defer db.Close()
+//
+//	mock.ExpectBegin()
+//	mock.ExpectExec("insert into samples").WithArgs("Bob").WillReturnResult(0, 1)
+//	mock.ExpectCommit()
+//
+//	if err := Sample(db, "Bob"); err != nil {
+//	    t.Fatal(err)
+//	}
+//
+//	if err := mock.ExpectationsWereMet(); err != nil {
+//	    t.Error(err)
+//	}
+package hermestest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sbowman/hermes"
+)
+
+// DriverName is the name the mock driver is registered under with
+// database/sql.
+const DriverName = "hermestest"
+
+// AnyArg matches any argument passed to a statement.
+var AnyArg Argument = anyArg{}
+
+// Argument may be passed to Expectation.WithArgs to customize how an argument
+// is matched.
+type Argument interface {
+	// Match returns true if the argument value is acceptable.
+	Match(value driver.Value) bool
+}
+
+// QueryMatcher compares the expected query to the query the code under test
+// executed.  Returns an error describing the mismatch, or nil if they match.
+type QueryMatcher func(expected, actual string) error
+
+// MatchRegexp treats the expected query as a regular expression.  This is the
+// default.
+func MatchRegexp(expected, actual string) error {
+	re, err := regexp.Compile(expected)
+	if err != nil {
+		return err
+	}
+
+	if !re.MatchString(actual) {
+		return fmt.Errorf("query %q doesn't match %q", actual, expected)
+	}
+
+	return nil
+}
+
+// MatchExact requires the expected query to equal the actual query, ignoring
+// leading and trailing whitespace.
+func MatchExact(expected, actual string) error {
+	if strings.TrimSpace(expected) != strings.TrimSpace(actual) {
+		return fmt.Errorf("query %q doesn't equal %q", actual, expected)
+	}
+
+	return nil
+}
+
+const (
+	_begin = iota
+	_commit
+	_rollback
+	_exec
+	_query
+	_prepare
+)
+
+var kindNames = map[int]string{
+	_begin:    "Begin",
+	_commit:   "Commit",
+	_rollback: "Rollback",
+	_exec:     "Exec",
+	_query:    "Query",
+	_prepare:  "Prepare",
+}
+
+// Mock is a scriptable database driver.  Expectations must be met in the
+// order they are declared.  Safe for use by multiple goroutines, though
+// concurrent statements will race to meet the next expectation.
+type Mock struct {
+	// Matcher compares expected and actual queries.  Defaults to
+	// MatchRegexp.
+	Matcher QueryMatcher
+
+	dsn          string
+	mu           sync.Mutex
+	expectations []*Expectation
+}
+
+var (
+	mocksMu sync.Mutex
+	mocks   = make(map[string]*Mock)
+	counter int
+)
+
+func init() {
+	sql.Register(DriverName, mockDriver{})
+}
+
+// New creates a new mock database driver.
+func New() *Mock {
+	mocksMu.Lock()
+	defer mocksMu.Unlock()
+
+	counter++
+
+	m := &Mock{
+		Matcher: MatchRegexp,
+		dsn:     fmt.Sprintf("hermestest_%d", counter),
+	}
+	mocks[m.dsn] = m
+
+	return m
+}
+
+// DB returns a Hermes database connection backed by the mock.  Options are
+// passed to hermes.NewDB.
+func (m *Mock) DB(opts ...hermes.Option) *hermes.DB {
+	db, err := sqlx.Open(DriverName, m.dsn)
+	if err != nil {
+		panic(err) // only happens if the driver isn't registered
+	}
+
+	return hermes.NewDB(m.dsn, db, nil, opts...)
+}
+
+// DataSourceName returns the name to open the mock with, along with
+// DriverName, e.g. to pass to hermes.Connect.
+func (m *Mock) DataSourceName() string {
+	return m.dsn
+}
+
+// ExpectBegin expects a transaction to begin.
+func (m *Mock) ExpectBegin() *Expectation {
+	return m.expect(_begin, "")
+}
+
+// ExpectCommit expects a transaction to commit.
+func (m *Mock) ExpectCommit() *Expectation {
+	return m.expect(_commit, "")
+}
+
+// ExpectRollback expects a transaction to roll back.
+func (m *Mock) ExpectRollback() *Expectation {
+	return m.expect(_rollback, "")
+}
+
+// ExpectExec expects a statement matching the query to be executed with Exec.
+func (m *Mock) ExpectExec(query string) *Expectation {
+	return m.expect(_exec, query)
+}
+
+// ExpectQuery expects a query matching the query to be executed with Query,
+// Row, Get, or Select.
+func (m *Mock) ExpectQuery(query string) *Expectation {
+	return m.expect(_query, query)
+}
+
+// ExpectPrepare expects a statement matching the query to be prepared.
+// Executing the prepared statement must be expected separately, with
+// ExpectExec or ExpectQuery.
+func (m *Mock) ExpectPrepare(query string) *Expectation {
+	return m.expect(_prepare, query)
+}
+
+// ExpectSavepoint expects a savepoint to be created, e.g. by Conn.Savepoint or
+// a nested transaction with NestedSavepoints enabled.
+func (m *Mock) ExpectSavepoint() *Expectation {
+	return m.expect(_exec, `^SAVEPOINT \w+$`).matchWith(MatchRegexp)
+}
+
+// ExpectRollbackTo expects a transaction to roll back to a savepoint.
+func (m *Mock) ExpectRollbackTo() *Expectation {
+	return m.expect(_exec, `^ROLLBACK TO SAVEPOINT \w+$`).matchWith(MatchRegexp)
+}
+
+// ExpectRelease expects a savepoint to be released.
+func (m *Mock) ExpectRelease() *Expectation {
+	return m.expect(_exec, `^RELEASE SAVEPOINT \w+$`).matchWith(MatchRegexp)
+}
+
+// ExpectationsWereMet returns an error if any expectations weren't met.
+func (m *Mock) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range m.expectations {
+		if !e.met {
+			return fmt.Errorf("hermestest: expectation not met: %s", e)
+		}
+	}
+
+	return nil
+}
+
+// Adds an expectation to the list.
+func (m *Mock) expect(kind int, query string) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := &Expectation{
+		kind:  kind,
+		query: query,
+	}
+	m.expectations = append(m.expectations, e)
+
+	return e
+}
+
+// Finds the next unmet expectation and confirms it matches the request.
+// Marks the expectation as met.
+func (m *Mock) next(kind int, query string, args []driver.NamedValue) (*Expectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var e *Expectation
+	for _, check := range m.expectations {
+		if !check.met {
+			e = check
+			break
+		}
+	}
+
+	if e == nil {
+		return nil, fmt.Errorf("hermestest: unexpected %s; all expectations were met", describe(kind, query))
+	}
+
+	if e.kind != kind {
+		return nil, fmt.Errorf("hermestest: unexpected %s; expected %s", describe(kind, query), e)
+	}
+
+	if kind == _exec || kind == _query || kind == _prepare {
+		matcher := e.matcher
+		if matcher == nil {
+			matcher = m.Matcher
+		}
+
+		if err := matcher(e.query, query); err != nil {
+			return nil, fmt.Errorf("hermestest: %s", err)
+		}
+	}
+
+	if e.args != nil {
+		if err := e.matchArgs(args); err != nil {
+			return nil, fmt.Errorf("hermestest: %s: %s", describe(kind, query), err)
+		}
+	}
+
+	e.met = true
+
+	return e, nil
+}
+
+// Expectation is a statement or transaction event the code under test is
+// expected to execute.
+type Expectation struct {
+	kind    int
+	query   string
+	matcher QueryMatcher
+	args    []interface{}
+	opts    *driver.TxOptions
+	result  driver.Result
+	rows    *Rows
+	err     error
+	delay   time.Duration
+	met     bool
+}
+
+// WithArgs expects the statement to be executed with these arguments.  Use
+// AnyArg or an Argument to loosen the match.
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	e.args = args
+	return e
+}
+
+// WithTxOptions expects the transaction to begin with these options.
+func (e *Expectation) WithTxOptions(opts sql.TxOptions) *Expectation {
+	e.opts = &driver.TxOptions{
+		Isolation: driver.IsolationLevel(opts.Isolation),
+		ReadOnly:  opts.ReadOnly,
+	}
+
+	return e
+}
+
+// WillReturnResult returns a result from Exec with the last insert ID and
+// number of rows affected.
+func (e *Expectation) WillReturnResult(lastInsertID, rowsAffected int64) *Expectation {
+	e.result = result{lastInsertID, rowsAffected}
+	return e
+}
+
+// WillReturnRows returns the rows from a query.
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// WillReturnError returns the error instead of a result.
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// WillDelayFor waits the duration before responding, e.g. to test timeouts.
+// If the statement's context is canceled first, returns the context error.
+func (e *Expectation) WillDelayFor(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+// String describes the expectation.
+func (e *Expectation) String() string {
+	return describe(e.kind, e.query)
+}
+
+// Overrides the mock's query matcher for this expectation.
+func (e *Expectation) matchWith(matcher QueryMatcher) *Expectation {
+	e.matcher = matcher
+	return e
+}
+
+// Compares the expected arguments to the actual arguments.
+func (e *Expectation) matchArgs(args []driver.NamedValue) error {
+	if len(args) != len(e.args) {
+		return fmt.Errorf("expected %d arguments; got %d", len(e.args), len(args))
+	}
+
+	for idx, expected := range e.args {
+		actual := args[idx].Value
+
+		if arg, ok := expected.(Argument); ok {
+			if !arg.Match(actual) {
+				return fmt.Errorf("argument %d (%v) doesn't match", idx+1, actual)
+			}
+
+			continue
+		}
+
+		value, err := driver.DefaultParameterConverter.ConvertValue(expected)
+		if err != nil {
+			return fmt.Errorf("invalid expected argument %d: %s", idx+1, err)
+		}
+
+		if !reflect.DeepEqual(value, actual) {
+			return fmt.Errorf("argument %d:  expected %v; got %v", idx+1, value, actual)
+		}
+	}
+
+	return nil
+}
+
+// Rows are the results of a query.
+type Rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// NewRows creates a set of results with the given columns.
+func NewRows(columns ...string) *Rows {
+	return &Rows{columns: columns}
+}
+
+// AddRow adds a row of values to the results.  There must be one value per
+// column.  Panics if a value can't be converted to a driver value.
+func (r *Rows) AddRow(values ...interface{}) *Rows {
+	if len(values) != len(r.columns) {
+		panic(fmt.Sprintf("hermestest: expected %d values; got %d", len(r.columns), len(values)))
+	}
+
+	row := make([]driver.Value, len(values))
+	for idx, v := range values {
+		value, err := driver.DefaultParameterConverter.ConvertValue(v)
+		if err != nil {
+			panic(fmt.Sprintf("hermestest: invalid value for %s: %s", r.columns[idx], err))
+		}
+
+		row[idx] = value
+	}
+
+	r.values = append(r.values, row)
+	return r
+}
+
+type anyArg struct{}
+
+func (anyArg) Match(driver.Value) bool {
+	return true
+}
+
+type result struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// Describes the request or expectation.
+func describe(kind int, query string) string {
+	if query == "" {
+		return kindNames[kind]
+	}
+
+	return fmt.Sprintf("%s %q", kindNames[kind], query)
+}
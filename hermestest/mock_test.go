@@ -0,0 +1,254 @@
+package hermestest_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+func TestExec(t *testing.T) {
+	mock := hermestest.New()
+	db := mock.DB()
+	defer db.Close()
+
+	mock.ExpectExec("insert into samples").WithArgs("Bob", hermestest.AnyArg).WillReturnResult(0, 1)
+
+	res, err := db.Exec("insert into samples (name, age) values ($1, $2)", "Bob", 35)
+	if err != nil {
+		t.Fatalf("Unable to exec: %s", err)
+	}
+
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Errorf("Expected one row affected; got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	mock := hermestest.New()
+	db := mock.DB()
+	defer db.Close()
+
+	mock.ExpectQuery("select name, age from people").
+		WillReturnRows(hermestest.NewRows("name", "age").AddRow("James", 35).AddRow("Jane", 42))
+
+	var people []struct {
+		Name string `db:"name"`
+		Age  int    `db:"age"`
+	}
+
+	if err := db.Select(&people, "select name, age from people"); err != nil {
+		t.Fatalf("Unable to select: %s", err)
+	}
+
+	if len(people) != 2 || people[0].Name != "James" || people[1].Age != 42 {
+		t.Errorf("Unexpected results: %+v", people)
+	}
+}
+
+func TestUnexpected(t *testing.T) {
+	mock := hermestest.New()
+	db := mock.DB()
+	defer db.Close()
+
+	mock.ExpectExec("insert into samples").WithArgs("Bob")
+
+	if _, err := db.Exec("delete from samples"); err == nil {
+		t.Error("Expected a mismatched query to fail")
+	}
+
+	if _, err := db.Exec("insert into samples values ($1)", "Frank"); err == nil {
+		t.Error("Expected mismatched arguments to fail")
+	}
+
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Error("Expected the insert to be unmet")
+	}
+}
+
+func TestMatchExact(t *testing.T) {
+	mock := hermestest.New()
+	mock.Matcher = hermestest.MatchExact
+
+	db := mock.DB()
+	defer db.Close()
+
+	mock.ExpectExec("delete from samples where id = $1").WithArgs(12)
+
+	if _, err := db.Exec("delete from samples where id = $1", 12); err != nil {
+		t.Errorf("Unable to exec: %s", err)
+	}
+}
+
+func TestDeepRollback(t *testing.T) {
+	mock := hermestest.New()
+	db := mock.DB()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("insert into wonders").WithArgs("Mahogany")
+	mock.ExpectExec("insert into wonders").WithArgs("Oak")
+	mock.ExpectRollback()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+	defer tx.Close()
+
+	if _, err := tx.Exec("insert into wonders values ($1)", "Mahogany"); err != nil {
+		t.Fatalf("Unable to insert: %s", err)
+	}
+
+	err = func(conn hermes.Conn) error {
+		txn, err := conn.Begin()
+		if err != nil {
+			return err
+		}
+		defer txn.Close()
+
+		if _, err := txn.Exec("insert into wonders values ($1)", "Oak"); err != nil {
+			return err
+		}
+
+		return txn.Rollback()
+	}(tx)
+
+	if err != nil {
+		t.Fatalf("Nested transaction failed: %s", err)
+	}
+
+	if !tx.RolledBack() {
+		t.Error("Expected the transaction to be rolled back")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNestedSavepoints(t *testing.T) {
+	mock := hermestest.New()
+	db := mock.DB(hermes.WithNestedSavepoints())
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectSavepoint()
+	mock.ExpectExec("insert into wonders").WillReturnError(errors.New("insert failed"))
+	mock.ExpectRollbackTo()
+	mock.ExpectRelease()
+	mock.ExpectSavepoint()
+	mock.ExpectRelease()
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+	defer tx.Close()
+
+	sample := func(conn hermes.Conn, fail bool) error {
+		txn, err := conn.Begin()
+		if err != nil {
+			return err
+		}
+		defer txn.Close()
+
+		if fail {
+			_, err := txn.Exec("insert into wonders values ('Oak')")
+			return err
+		}
+
+		return txn.Commit()
+	}
+
+	if err := sample(tx, true); err == nil {
+		t.Error("Expected the first nested transaction to fail")
+	}
+
+	if err := sample(tx, false); err != nil {
+		t.Errorf("Second nested transaction failed: %s", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Errorf("Unable to commit: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTxOptions(t *testing.T) {
+	mock := hermestest.New()
+	db := mock.DB()
+	defer db.Close()
+
+	opts := sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true}
+
+	mock.ExpectBegin().WithTxOptions(opts)
+	mock.ExpectRollback()
+
+	tx, err := db.BeginTx(context.Background(), &opts)
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+
+	if err := tx.Close(); err != nil {
+		t.Errorf("Unable to close transaction: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInTxRetry(t *testing.T) {
+	mock := hermestest.New()
+	db := mock.DB(hermes.WithRetry(hermes.RetryPolicy{Attempts: 2}))
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("update ledger").WillReturnError(&pq.Error{Code: "40001"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("update ledger").WillReturnResult(0, 1)
+	mock.ExpectCommit()
+
+	err := hermes.InTx(context.Background(), db, nil, func(conn hermes.Conn) error {
+		_, err := conn.Exec("update ledger set balance = balance - 10")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	mock := hermestest.New()
+	db := mock.DB()
+	defer db.Close()
+
+	mock.ExpectQuery("select pg_sleep").WillDelayFor(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var v string
+	if err := db.GetContext(ctx, &v, "select pg_sleep(1)"); !hermes.IsQueryCanceled(err) {
+		t.Errorf("Expected the query to be canceled; got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package hermestest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sbowman/hermes"
+)
+
+// Tx begins a transaction for the duration of the test.  The transaction
+// behaves like a hermes.MockTx:  the code under test may begin, commit, and
+// roll back nested transactions, which are backed by savepoints, but the
+// outermost transaction never commits.  When the test completes, the
+// transaction is rolled back, leaving the database as it was found.
+//
+// If the code under test left any nested transactions open, the test fails
+// with the stack trace of where each leaked transaction began.
+//
+// Each call begins its own transaction on its own connection, so tests using
+// Tx may call t.Parallel().  Make sure the database's connection pool is
+// large enough for the tests running in parallel.
+//
+//	func TestSample(t *testing.T) {
+//	    t.Parallel()
+//
+//	    conn := hermestest.Tx(t, db)
+//	    if err := Sample(conn, "Bob"); err != nil {
+//	        t.Fatal(err)
+//	    }
+//	}
+func Tx(t testing.TB, db *hermes.DB) hermes.Conn {
+	t.Helper()
+
+	mock := &hermes.MockDB{DB: db, NestedSavepoints: true}
+
+	// Begin without a context, so the code under test may begin nested
+	// transactions with its own
+	conn, err := mock.Begin()
+	if err != nil {
+		t.Fatalf("hermestest: unable to begin transaction: %s", err)
+	}
+
+	tx := conn.(*hermes.MockTx)
+
+	t.Cleanup(func() {
+		if leaked := tx.OpenNested(); len(leaked) > 0 {
+			t.Errorf("hermestest: %d nested transaction(s) left open; begun at:\n\n%s",
+				len(leaked), strings.Join(leaked, "\n"))
+
+			for tx.Depth() > 0 {
+				if err := tx.Close(); err != nil {
+					break
+				}
+			}
+		}
+
+		if err := tx.Close(); err != nil {
+			t.Errorf("hermestest: unable to roll back transaction: %s", err)
+		}
+	})
+
+	return tx
+}
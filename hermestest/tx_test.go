@@ -0,0 +1,141 @@
+package hermestest_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+// Captures failures and cleanup functions, so the test can confirm Tx fails
+// the test when it should.
+type recorder struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Cleanup(fn func()) {
+	r.cleanups = append(r.cleanups, fn)
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	panic("fatal")
+}
+
+func (r *recorder) cleanup() {
+	for idx := len(r.cleanups) - 1; idx >= 0; idx-- {
+		r.cleanups[idx]()
+	}
+}
+
+func TestTx(t *testing.T) {
+	mock := hermestest.New()
+	db := mock.DB()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectSavepoint()
+	mock.ExpectExec("insert into samples").WithArgs("Bob")
+	mock.ExpectRelease()
+	mock.ExpectRollback()
+
+	t.Run("Sample", func(t *testing.T) {
+		conn := hermestest.Tx(t, db)
+
+		err := hermes.InTx(context.Background(), conn, nil, func(conn hermes.Conn) error {
+			_, err := conn.Exec("insert into samples values ($1)", "Bob")
+			return err
+		})
+		if err != nil {
+			t.Errorf("Transaction failed: %s", err)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTxBeginCtx(t *testing.T) {
+	mock := hermestest.New()
+	db := mock.DB()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectSavepoint()
+	mock.ExpectExec("insert into samples").WithArgs("Bob")
+	mock.ExpectRelease()
+	mock.ExpectRollback()
+
+	t.Run("Sample", func(t *testing.T) {
+		conn := hermestest.Tx(t, db)
+
+		// The code under test begins a transaction with its own context,
+		// e.g. a request's
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		tx, err := conn.BeginCtx(ctx)
+		if err != nil {
+			t.Fatalf("Unable to begin transaction with the test's context: %s", err)
+		}
+		defer tx.Close()
+
+		if _, err := tx.Exec("insert into samples values ($1)", "Bob"); err != nil {
+			t.Errorf("Unable to insert: %s", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			t.Errorf("Unable to commit: %s", err)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTxLeak(t *testing.T) {
+	mock := hermestest.New()
+	db := mock.DB()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectSavepoint()
+	mock.ExpectRollbackTo()
+	mock.ExpectRelease()
+	mock.ExpectRollback()
+
+	r := &recorder{TB: t}
+	conn := hermestest.Tx(r, db)
+
+	if _, err := conn.Begin(); err != nil {
+		t.Fatalf("Unable to begin nested transaction: %s", err)
+	}
+
+	r.cleanup()
+
+	if len(r.errors) != 1 {
+		t.Fatalf("Expected the leaked transaction to fail the test; got %q", r.errors)
+	}
+
+	if !strings.Contains(r.errors[0], "TestTxLeak") {
+		t.Errorf("Expected the failure to include where the transaction began; got %s", r.errors[0])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
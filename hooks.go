@@ -0,0 +1,232 @@
+package hermes
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Operations reported in a QueryEvent.
+const (
+	OpExec    = "Exec"
+	OpQuery   = "Query"
+	OpRow     = "Row"
+	OpPrepare = "Prepare"
+	OpGet     = "Get"
+	OpSelect  = "Select"
+)
+
+// Hook observes the statements executed by Hermes, e.g. for logging, metrics,
+// or tracing.  Register hooks on the database connection with DB.AddHook;
+// every transaction created by the database inherits its hooks.
+type Hook interface {
+	// Before is called before the statement is executed.  The returned
+	// context is passed to After, so it may carry state between the two,
+	// such as a tracing span.  The context is only used by the hooks; it is
+	// not passed to the database driver.
+	Before(ctx context.Context, event *QueryEvent) context.Context
+
+	// After is called once the statement completes, with the Duration and
+	// Err of the event set.
+	After(ctx context.Context, event *QueryEvent)
+}
+
+// QueryEvent describes a statement executed by Hermes.
+type QueryEvent struct {
+	// Op is the Conn function that executed the statement, e.g. OpExec.
+	Op string
+
+	// Query is the text of the statement.
+	Query string
+
+	// Args are the arguments passed with the statement.
+	Args []interface{}
+
+	// Start is the time the statement started.
+	Start time.Time
+
+	// Duration is how long the statement took.  Set before After is
+	// called.
+	Duration time.Duration
+
+	// Err is the error returned by the database, if any.  Set before After
+	// is called.
+	Err error
+
+	// RowsAffected is the number of rows affected by an Exec, or -1 if
+	// unknown.  Set before After is called.
+	RowsAffected int64
+
+	// InTx is true if the statement was executed in a transaction.
+	InTx bool
+
+	// Depth is the nesting depth of the transaction, zero for the outermost
+	// transaction or outside of a transaction.
+	Depth int
+
+	tx *Tx // the transaction, if any
+}
+
+// TxHook may be implemented by a Hook to also observe database transactions,
+// e.g. to time them or create a tracing span for each one.  Only the outermost
+// transaction is reported; nested transactions share it.
+type TxHook interface {
+	// BeginTx is called when the transaction begins, with the context
+	// passed to BeginCtx or BeginTx.  The returned context is passed to
+	// EndTx, and to the Before function of the hooks for every statement
+	// in the transaction in place of the statement's context, so it may
+	// carry state such as a tracing span.
+	BeginTx(ctx context.Context, event *TxEvent) context.Context
+
+	// EndTx is called once the transaction commits or rolls back, with the
+	// Duration, Committed, and MaxDepth of the event set.
+	EndTx(ctx context.Context, event *TxEvent)
+}
+
+// TxEvent describes a database transaction.
+type TxEvent struct {
+	// ID uniquely identifies the transaction; see TxInfo.
+	ID uint64
+
+	// Opts are the options the transaction began with, if any.
+	Opts *sql.TxOptions
+
+	// Start is the time the transaction began.
+	Start time.Time
+
+	// Duration is how long the transaction was open.  Set before EndTx is
+	// called.
+	Duration time.Duration
+
+	// Committed is true if the transaction committed, false if it rolled
+	// back.  Set before EndTx is called.
+	Committed bool
+
+	// MaxDepth is the deepest the transaction was nested.  Set before EndTx
+	// is called.
+	MaxDepth int
+}
+
+// AddHook registers a hook with the database connection.  Hooks are called in
+// the order they are added before a statement or transaction, and in the
+// reverse order after.
+//
+// Hooks should be added when the database connection is created, before it is
+// used; AddHook is not safe to call while queries are running.
+func (db *DB) AddHook(hook Hook) {
+	db.hooks = append(db.hooks, hook)
+}
+
+// Runs the statement function, calling the hooks before and after.
+func (db *DB) run(ctx context.Context, op, query string, args []interface{}, tx *Tx, fn func() error) error {
+	_, err := db.runResult(ctx, op, query, args, tx, func() (sql.Result, error) {
+		return nil, fn()
+	})
+
+	return err
+}
+
+// Runs the Exec function, calling the hooks before and after.  Reports the
+// rows affected to the hooks.
+func (db *DB) runExec(ctx context.Context, query string, args []interface{}, tx *Tx, fn func() (sql.Result, error)) (sql.Result, error) {
+	return db.runResult(ctx, OpExec, query, args, tx, fn)
+}
+
+// Runs the statement function, calling the hooks before and after.  If the
+// function returns a result, the rows affected are added to the event.  The
+// hooks see the original error; the caller gets it wrapped in a QueryError.
+func (db *DB) runResult(ctx context.Context, op, query string, args []interface{}, tx *Tx, fn func() (sql.Result, error)) (sql.Result, error) {
+	start := time.Now()
+
+	if len(db.hooks) == 0 {
+		res, err := fn()
+		return res, wrapQueryError(op, query, args, tx, start, err)
+	}
+
+	event := &QueryEvent{
+		Op:           op,
+		Query:        query,
+		Args:         args,
+		Start:        start,
+		RowsAffected: -1,
+	}
+
+	if tx != nil {
+		event.InTx = true
+		event.Depth = tx.Depth()
+		event.tx = tx
+
+		// Statements in a transaction see the context from the TxHooks
+		if tx.hookCtx != nil {
+			ctx = tx.hookCtx
+		}
+	}
+
+	for _, hook := range db.hooks {
+		ctx = hook.Before(ctx, event)
+	}
+
+	res, err := fn()
+
+	event.Duration = time.Since(event.Start)
+	event.Err = err
+
+	if res != nil {
+		if n, err := res.RowsAffected(); err == nil {
+			event.RowsAffected = n
+		}
+	}
+
+	for idx := len(db.hooks) - 1; idx >= 0; idx-- {
+		db.hooks[idx].After(ctx, event)
+	}
+
+	return res, wrapQueryError(op, query, args, tx, start, err)
+}
+
+// Calls the BeginTx function of every TxHook, saving the event and context on
+// the transaction for endTx.
+func (db *DB) beginTx(ctx context.Context, tx *Tx) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	for _, hook := range db.hooks {
+		h, ok := hook.(TxHook)
+		if !ok {
+			continue
+		}
+
+		if tx.event == nil {
+			tx.event = &TxEvent{
+				ID:    tx.record.id,
+				Opts:  tx.opts,
+				Start: time.Now(),
+			}
+		}
+
+		ctx = h.BeginTx(ctx, tx.event)
+	}
+
+	if tx.event != nil {
+		tx.hookCtx = ctx
+	}
+}
+
+// Calls the EndTx function of every TxHook once the transaction commits or
+// rolls back.
+func (db *DB) endTx(tx *Tx, committed bool) {
+	if tx.event == nil {
+		return
+	}
+
+	tx.event.Duration = time.Since(tx.event.Start)
+	tx.event.Committed = committed
+	tx.event.MaxDepth = tx.maxDepth
+
+	for idx := len(db.hooks) - 1; idx >= 0; idx-- {
+		if h, ok := db.hooks[idx].(TxHook); ok {
+			h.EndTx(tx.hookCtx, tx.event)
+		}
+	}
+}
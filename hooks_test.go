@@ -0,0 +1,134 @@
+package hermes_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+// Records the events passed to the hook.
+type recorder struct {
+	before []hermes.QueryEvent
+	after  []hermes.QueryEvent
+}
+
+func (r *recorder) Before(ctx context.Context, event *hermes.QueryEvent) context.Context {
+	r.before = append(r.before, *event)
+	return ctx
+}
+
+func (r *recorder) After(ctx context.Context, event *hermes.QueryEvent) {
+	r.after = append(r.after, *event)
+}
+
+func TestHooks(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	var hook recorder
+	db.AddHook(&hook)
+
+	if _, err := db.Exec("select $1::int", 1); err != nil {
+		t.Fatalf("Unable to exec: %s", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction :%s", err)
+	}
+	defer tx.Close()
+
+	txn, err := tx.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start nested transaction :%s", err)
+	}
+	defer txn.Close()
+
+	var missing int
+	if err := txn.Get(&missing, "select count(1) from missing_table"); err == nil {
+		t.Fatal("Expected get to fail on a missing table")
+	}
+
+	if len(hook.before) != 2 || len(hook.after) != 2 {
+		t.Fatalf("Expected two events; got %d before and %d after", len(hook.before), len(hook.after))
+	}
+
+	exec := hook.after[0]
+	if exec.Op != hermes.OpExec || exec.Query != "select $1::int" || len(exec.Args) != 1 {
+		t.Errorf("Unexpected exec event: %+v", exec)
+	}
+
+	if exec.InTx || exec.Err != nil || exec.Duration <= 0 {
+		t.Errorf("Unexpected exec event: %+v", exec)
+	}
+
+	get := hook.after[1]
+	if get.Op != hermes.OpGet || !get.InTx || get.Depth != 1 {
+		t.Errorf("Unexpected get event: %+v", get)
+	}
+
+	if get.Err == nil {
+		t.Error("Expected the get event to include the error")
+	}
+}
+
+// Records the transactions passed to the hook.
+type txRecorder struct {
+	recorder
+	ended []hermes.TxEvent
+}
+
+type txKey struct{}
+
+func (r *txRecorder) BeginTx(ctx context.Context, event *hermes.TxEvent) context.Context {
+	return context.WithValue(ctx, txKey{}, event.ID)
+}
+
+func (r *txRecorder) Before(ctx context.Context, event *hermes.QueryEvent) context.Context {
+	if ctx.Value(txKey{}) == nil {
+		panic("expected the transaction context")
+	}
+
+	return r.recorder.Before(ctx, event)
+}
+
+func (r *txRecorder) EndTx(ctx context.Context, event *hermes.TxEvent) {
+	r.ended = append(r.ended, *event)
+}
+
+func TestTxHook(t *testing.T) {
+	fake := hermestest.New()
+	db := fake.DB()
+	defer db.Close()
+
+	var hook txRecorder
+	db.AddHook(&hook)
+
+	fake.ExpectBegin()
+	fake.ExpectExec("update samples")
+	fake.ExpectCommit()
+
+	err := hermes.InTx(context.Background(), db, nil, func(conn hermes.Conn) error {
+		return hermes.InTx(context.Background(), conn, nil, func(conn hermes.Conn) error {
+			_, err := conn.Exec("update samples set name = 'Bob'")
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %s", err)
+	}
+
+	if len(hook.ended) != 1 {
+		t.Fatalf("Expected one transaction; got %d", len(hook.ended))
+	}
+
+	if event := hook.ended[0]; !event.Committed || event.MaxDepth != 1 || event.Duration <= 0 {
+		t.Errorf("Unexpected transaction event %+v", event)
+	}
+
+	if len(hook.after) != 1 || hook.after[0].Depth != 1 {
+		t.Errorf("Expected one statement at depth 1; got %+v", hook.after)
+	}
+}
@@ -0,0 +1,141 @@
+package hermes
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// TxFn is a function run inside a transaction by InTx.  The Conn is the
+// transaction; don't commit or close it, InTx takes care of that.
+type TxFn func(conn Conn) error
+
+// RetryPolicy configures how InTx retries a transaction that fails with a
+// retryable error, such as a serialization failure or a deadlock.
+type RetryPolicy struct {
+	// Attempts is the maximum number of times to run the transaction,
+	// including the first attempt.  Zero or one disables retries.
+	Attempts int
+
+	// Backoff returns how long to wait before the given retry, starting at
+	// one for the first retry.  If nil, retries immediately.
+	Backoff func(retry int) time.Duration
+}
+
+// Retry is the default retry policy used by InTx:  up to three attempts,
+// backing off exponentially between them.
+var Retry = RetryPolicy{
+	Attempts: 3,
+	Backoff:  ExponentialBackoff(10*time.Millisecond, time.Second),
+}
+
+// ExponentialBackoff returns a backoff function for a RetryPolicy that waits
+// the base duration before the first retry, and doubles the wait for each
+// subsequent retry, up to the max duration.
+func ExponentialBackoff(base, max time.Duration) func(retry int) time.Duration {
+	return func(retry int) time.Duration {
+		wait := base
+		for idx := 1; idx < retry && wait < max; idx++ {
+			wait *= 2
+		}
+
+		if wait > max {
+			return max
+		}
+
+		return wait
+	}
+}
+
+// InTx runs the function in a transaction, using the retry policy configured
+// on the database with WithRetry, or the default Retry policy.  See InTxRetry.
+func InTx(ctx context.Context, conn Conn, opts *sql.TxOptions, fn TxFn) error {
+	policy := Retry
+	if db, ok := conn.(interface{ retryPolicy() RetryPolicy }); ok {
+		policy = db.retryPolicy()
+	}
+
+	return InTxRetry(ctx, conn, opts, policy, fn)
+}
+
+// InTxRetry begins a transaction on the connection, or joins the connection's
+// existing transaction, and runs the function in it.  If the function returns
+// nil, the transaction is committed; if it returns an error or panics, the
+// transaction is rolled back.
+//
+// When joining a transaction begun with a context, the function runs on the
+// transaction's context.  The deadline and cancellation of the context passed
+// in are ignored, other than returning its error if it's already done.
+//
+// When InTxRetry starts the outermost transaction and the function or commit
+// fails with a retryable error (see IsRetryable), the entire function is run
+// again in a new transaction, according to the retry policy.  Nested calls
+// never retry; they return the error so the outermost call may retry.
+func InTxRetry(ctx context.Context, conn Conn, opts *sql.TxOptions, policy RetryPolicy, fn TxFn) error {
+	if conn.BaseTx() != nil {
+		return runTx(ctx, conn, opts, fn)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, conn, opts, fn)
+		if err == nil || attempt >= policy.Attempts || !IsRetryable(err) {
+			return err
+		}
+
+		if policy.Backoff == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(policy.Backoff(attempt)):
+		}
+	}
+}
+
+// IsRetryable returns true if the error indicates the transaction failed
+// because of concurrent activity and may succeed if run again, i.e. a
+// PostgreSQL serialization failure (40001) or deadlock (40P01).
+func IsRetryable(err error) bool {
+	kind := Classify(err)
+	return kind == KindSerializationFailure || kind == KindDeadlock
+}
+
+// Runs the function in a single transaction.  A transaction can't switch
+// contexts partway through, so when joining a transaction that has a context,
+// the nested transaction runs on the parent's context and the caller's is
+// ignored, other than failing if it's already done.
+func runTx(ctx context.Context, conn Conn, opts *sql.TxOptions, fn TxFn) error {
+	if conn.BaseTx() != nil && conn.Context() != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ctx = conn.Context()
+	}
+
+	tx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Close()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Close()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Close()
+		return err
+	}
+
+	return tx.Close()
+}
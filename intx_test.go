@@ -0,0 +1,180 @@
+package hermes_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+func TestInTxCommit(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	if _, err := db.Exec("create table test_in_tx(wonder varchar(64))"); err != nil {
+		t.Fatalf("Unable to create test_in_tx table: %s", err)
+	}
+	defer func() {
+		db.Exec("drop table test_in_tx")
+	}()
+
+	err := hermes.InTx(context.Background(), db, nil, func(conn hermes.Conn) error {
+		_, err := conn.Exec("insert into test_in_tx values ($1)", "Colossus")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %s", err)
+	}
+
+	var count int
+	if err := db.Get(&count, "select count(1) from test_in_tx"); err != nil {
+		t.Fatalf("Failed to get test_in_tx record count: %s", err)
+	}
+
+	if count != 1 {
+		t.Errorf("Expected one record; got %d", count)
+	}
+}
+
+func TestInTxRetry(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	policy := hermes.RetryPolicy{
+		Attempts: 3,
+		Backoff:  hermes.ExponentialBackoff(time.Millisecond, 10*time.Millisecond),
+	}
+
+	var attempts int
+
+	err := hermes.InTxRetry(context.Background(), db, nil, policy, func(conn hermes.Conn) error {
+		attempts++
+		if attempts == 1 {
+			return &pq.Error{Code: "40001"}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %s", err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("Expected two attempts; got %d", attempts)
+	}
+}
+
+func TestInTxNestedNoRetry(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	var attempts int
+
+	err := hermes.InTx(context.Background(), db, nil, func(conn hermes.Conn) error {
+		attempts++
+
+		return hermes.InTx(context.Background(), conn, nil, func(conn hermes.Conn) error {
+			return errors.New("nested failure")
+		})
+	})
+	if err == nil {
+		t.Fatal("Expected the nested failure to be returned")
+	}
+
+	if attempts != 1 {
+		t.Errorf("Expected one attempt; got %d", attempts)
+	}
+}
+
+func TestInTxPanic(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	var conn hermes.Conn
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected the panic to be passed through")
+		}
+
+		if !conn.RolledBack() {
+			t.Error("Expected the transaction to be rolled back")
+		}
+	}()
+
+	hermes.InTx(context.Background(), db, nil, func(tx hermes.Conn) error {
+		conn = tx
+		panic("oops")
+	})
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := hermes.ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+
+	for retry, expected := range []time.Duration{10, 20, 40, 50, 50} {
+		if wait := backoff(retry + 1); wait != expected*time.Millisecond {
+			t.Errorf("Expected retry %d to wait %s; was %s", retry+1, expected*time.Millisecond, wait)
+		}
+	}
+}
+
+func TestInTxNestedDerivedContext(t *testing.T) {
+	fake := hermestest.New()
+	db := fake.DB()
+	defer db.Close()
+
+	fake.ExpectBegin()
+	fake.ExpectExec("insert into samples")
+	fake.ExpectCommit()
+
+	ctx := context.Background()
+
+	err := hermes.InTx(ctx, db, nil, func(conn hermes.Conn) error {
+		derived, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+
+		return hermes.InTx(derived, conn, nil, func(conn hermes.Conn) error {
+			_, err := conn.Exec("insert into samples values ('Bob')")
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("Expected the nested transaction to join the outer one: %s", err)
+	}
+
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInTxNestedCanceledContext(t *testing.T) {
+	fake := hermestest.New()
+	db := fake.DB()
+	defer db.Close()
+
+	fake.ExpectBegin()
+	fake.ExpectRollback()
+
+	ctx := context.Background()
+
+	err := hermes.InTx(ctx, db, nil, func(conn hermes.Conn) error {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		return hermes.InTx(canceled, conn, nil, func(conn hermes.Conn) error {
+			t.Error("Expected the nested transaction not to run with a canceled context")
+			return nil
+		})
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf(`Expected error "%s"; got "%v"`, context.Canceled, err)
+	}
+
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,77 @@
+package hermes
+
+// OnCommit calls the function immediately; there is no transaction to wait
+// for on the DB.
+func (db *DB) OnCommit(fn func()) {
+	fn()
+}
+
+// OnRollback does nothing on the DB.
+func (db *DB) OnRollback(fn func()) {
+}
+
+// OnCommit registers a function to call after the database transaction
+// commits, e.g. to publish an event or invalidate a cache.  Committing a
+// nested transaction doesn't call the function; it waits for the outermost
+// transaction to commit.  If the transaction rolls back instead, the function
+// is discarded.
+//
+// With NestedSavepoints enabled, functions registered in a nested transaction
+// are discarded if the nested transaction is rolled back.
+func (tx *Tx) OnCommit(fn func()) {
+	if tx.rollback {
+		return
+	}
+
+	if tx.current == _commit && len(tx.history) == 0 {
+		fn()
+		return
+	}
+
+	tx.onCommit = append(tx.onCommit, fn)
+}
+
+// OnRollback registers a function to call after the database transaction
+// rolls back.  If the transaction commits instead, the function is discarded.
+//
+// With NestedSavepoints enabled, functions registered in a nested transaction
+// are called when the nested transaction is rolled back to its savepoint.
+func (tx *Tx) OnRollback(fn func()) {
+	if tx.rollback {
+		fn()
+		return
+	}
+
+	if tx.current == _commit && len(tx.history) == 0 {
+		return
+	}
+
+	tx.onRollback = append(tx.onRollback, fn)
+}
+
+// Called once the database transaction commits or rolls back, to remove the
+// transaction from the registry, release its connection pool, notify the
+// transaction hooks, and call the OnCommit or OnRollback functions.  Only runs
+// once.
+func (tx *Tx) finish(committed bool) {
+	if tx.finished {
+		return
+	}
+	tx.finished = true
+
+	tx.db.untrack(tx.record)
+	tx.db.release(tx.pool)
+	tx.db.endTx(tx, committed)
+
+	fns := tx.onRollback
+	if committed {
+		fns = tx.onCommit
+	}
+
+	tx.onCommit = nil
+	tx.onRollback = nil
+
+	for _, fn := range fns {
+		fn()
+	}
+}
@@ -0,0 +1,131 @@
+package hermes_test
+
+import (
+	"testing"
+
+	"github.com/sbowman/hermes"
+)
+
+func TestOnCommit(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	var committed, rolledBack bool
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction :%s", err)
+	}
+	defer tx.Close()
+
+	err = func(conn hermes.Conn) error {
+		txn, err := conn.Begin()
+		if err != nil {
+			return err
+		}
+		defer txn.Close()
+
+		txn.OnCommit(func() { committed = true })
+		txn.OnRollback(func() { rolledBack = true })
+
+		return txn.Commit()
+	}(tx)
+
+	if err != nil {
+		t.Fatalf("Nested tx failed unexpectedly: %s", err)
+	}
+
+	if committed {
+		t.Error("Nested commit shouldn't call OnCommit")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Unable to commit: %s", err)
+	}
+
+	if !committed {
+		t.Error("Expected commit to call OnCommit")
+	}
+
+	if rolledBack {
+		t.Error("Commit shouldn't call OnRollback")
+	}
+}
+
+func TestOnRollback(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	var committed, rolledBack bool
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction :%s", err)
+	}
+
+	tx.OnCommit(func() { committed = true })
+	tx.OnRollback(func() { rolledBack = true })
+
+	if err := tx.Close(); err != nil {
+		t.Fatalf("Unable to close transaction: %s", err)
+	}
+
+	if committed {
+		t.Error("Rollback shouldn't call OnCommit")
+	}
+
+	if !rolledBack {
+		t.Error("Expected rollback to call OnRollback")
+	}
+}
+
+func TestOnRollbackNestedSavepoint(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	db.NestedSavepoints = true
+
+	var committed, rolledBack bool
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction :%s", err)
+	}
+	defer tx.Close()
+
+	txn, err := tx.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start nested transaction :%s", err)
+	}
+
+	txn.OnCommit(func() { committed = true })
+	txn.OnRollback(func() { rolledBack = true })
+
+	if err := txn.Close(); err != nil {
+		t.Fatalf("Unable to close nested transaction: %s", err)
+	}
+
+	if !rolledBack {
+		t.Error("Expected nested rollback to call OnRollback")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Unable to commit: %s", err)
+	}
+
+	if committed {
+		t.Error("Expected nested rollback to discard OnCommit")
+	}
+}
+
+func TestOnCommitDB(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	var committed bool
+	db.OnCommit(func() { committed = true })
+
+	if !committed {
+		t.Error("Expected OnCommit to be called immediately on the database")
+	}
+}
@@ -0,0 +1,140 @@
+package hermes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LogLevel is the severity of a log message.
+type LogLevel int
+
+// Log levels, from least to most severe.
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+var logLevels = map[LogLevel]string{
+	LogDebug: "DEBUG",
+	LogInfo:  "INFO",
+	LogWarn:  "WARN",
+	LogError: "ERROR",
+}
+
+// String returns the level's name, e.g. "WARN".
+func (l LogLevel) String() string {
+	if name, ok := logLevels[l]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+// Logger receives the messages Hermes reports:  transaction timeouts, health
+// changes, connection failures, and, if enabled with WithQueryLog, every
+// statement executed.  Fields are alternating key/value pairs, e.g.
+// "depth", 1.
+//
+// Implementations must be safe for use by multiple goroutines.  See StdLogger
+// and SlogLogger for adapters.
+type Logger interface {
+	Log(level LogLevel, msg string, fields ...interface{})
+}
+
+// DefaultLogger receives messages from databases not configured with
+// WithLogger.  Defaults to writing warnings and errors to os.Stderr.
+var DefaultLogger = StdLogger(nil, LogWarn)
+
+// StdLogger adapts a *log.Logger from the standard library.  Messages below
+// the minimum level are discarded.  Fields are written as key=value after the
+// message; values spanning multiple lines, such as stack traces, follow on
+// their own lines.  If logger is nil, messages are written to os.Stderr.
+func StdLogger(logger *log.Logger, min LogLevel) Logger {
+	return &stdLogger{logger: logger, min: min}
+}
+
+type stdLogger struct {
+	logger *log.Logger
+	min    LogLevel
+}
+
+func (l *stdLogger) Log(level LogLevel, msg string, fields ...interface{}) {
+	if level < l.min {
+		return
+	}
+
+	var b strings.Builder
+	var trailing []string
+
+	b.WriteString(level.String())
+	b.WriteString(" ")
+	b.WriteString(msg)
+
+	for idx := 0; idx < len(fields); idx += 2 {
+		key := fmt.Sprint(fields[idx])
+
+		var value string
+		if idx+1 < len(fields) {
+			value = fmt.Sprint(fields[idx+1])
+		}
+
+		if strings.Contains(value, "\n") {
+			trailing = append(trailing, fmt.Sprintf("%s:\n%s", key, strings.TrimRight(value, "\n")))
+			continue
+		}
+
+		if value == "" || strings.ContainsAny(value, " \t\"=") {
+			value = strconv.Quote(value)
+		}
+
+		fmt.Fprintf(&b, " %s=%s", key, value)
+	}
+
+	for _, t := range trailing {
+		b.WriteString("\n")
+		b.WriteString(t)
+	}
+
+	// Look up os.Stderr on each call, in case it's redirected
+	if l.logger == nil {
+		fmt.Fprintln(os.Stderr, b.String())
+		return
+	}
+
+	l.logger.Print(b.String())
+}
+
+// Query logging hook, enabled with WithQueryLog.
+type queryLog struct {
+	logger Logger
+	level  LogLevel
+}
+
+func (q queryLog) Before(ctx context.Context, _ *QueryEvent) context.Context {
+	return ctx
+}
+
+func (q queryLog) After(_ context.Context, event *QueryEvent) {
+	fields := []interface{}{
+		"op", event.Op,
+		"query", event.Query,
+		"args", len(event.Args),
+		"duration", event.Duration,
+	}
+
+	if event.InTx {
+		fields = append(fields, "depth", event.Depth)
+	}
+
+	if event.Err != nil {
+		fields = append(fields, "err", event.Err)
+	}
+
+	q.logger.Log(q.level, "Query", fields...)
+}
@@ -0,0 +1,49 @@
+package hermes_test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := hermes.StdLogger(log.New(&buf, "", 0), hermes.LogInfo)
+	logger.Log(hermes.LogDebug, "Hidden")
+	logger.Log(hermes.LogWarn, "Something happened", "depth", 2, "query", "select 1", "stack", "main.main\n\tmain.go:12\n")
+
+	expected := "WARN Something happened depth=2 query=\"select 1\"\nstack:\nmain.main\n\tmain.go:12\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q; got %q", expected, buf.String())
+	}
+}
+
+func TestQueryLog(t *testing.T) {
+	var buf bytes.Buffer
+
+	fake := hermestest.New()
+	db := fake.DB(
+		hermes.WithLogger(hermes.StdLogger(log.New(&buf, "", 0), hermes.LogDebug)),
+		hermes.WithQueryLog(hermes.LogDebug))
+	defer db.Close()
+
+	fake.ExpectExec("delete from samples").WithArgs("Bob")
+
+	if _, err := db.Exec("delete from samples where name = $1", "Bob"); err != nil {
+		t.Fatalf("Unable to exec: %s", err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, `DEBUG Query op=Exec query="delete from samples where name = $1" args=1 duration=`) {
+		t.Errorf("Unexpected query log: %s", out)
+	}
+
+	if strings.Contains(out, "Bob") {
+		t.Errorf("Argument values shouldn't be logged: %s", out)
+	}
+}
@@ -0,0 +1,80 @@
+// Package metrics collects query, transaction, and connection pool metrics
+// from Hermes databases, and exposes them in the Prometheus text format
+// without depending on the Prometheus client library.
+//
+//	reg := metrics.New()
+//
+//	db, err := hermes.Connect("postgres", URI, 10, 2, hermes.WithHooks(reg.Hook()))
+//	if err != nil {
+//	    return err
+//	}
+//	reg.Register("primary", db)
+//
+//	http.Handle("/metrics", reg)
+//
+// To send the measurements somewhere else, implement a Collector and register
+// metrics.Hook(collector) with the database.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sbowman/hermes"
+)
+
+// Collector receives measurements from Hermes.  Implementations must be safe
+// for use by multiple goroutines.
+type Collector interface {
+	// ObserveQuery is called after every statement, with the operation,
+	// e.g. hermes.OpExec, how long the statement took, and, if the
+	// statement failed, the SQLSTATE class of the error (see
+	// hermes.ErrorClass) and whether the error was a connection failure
+	// (see hermes.DidConnectionFail).  The class is blank if the statement
+	// succeeded.
+	ObserveQuery(op string, duration time.Duration, class string, connFailed bool)
+
+	// ObserveTx is called after every transaction commits or rolls back,
+	// with how long the transaction was open and the deepest it was
+	// nested.
+	ObserveTx(duration time.Duration, committed bool, depth int)
+}
+
+// Hook returns a hermes.Hook that sends measurements to the collector.  The
+// hook also implements hermes.TxHook to measure transactions.  Register it
+// with hermes.WithHooks or DB.AddHook.
+func Hook(c Collector) hermes.Hook {
+	return hook{c}
+}
+
+type hook struct {
+	collector Collector
+}
+
+func (h hook) Before(ctx context.Context, _ *hermes.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h hook) After(_ context.Context, event *hermes.QueryEvent) {
+	var (
+		class      string
+		connFailed bool
+	)
+
+	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
+		class = hermes.ErrorClass(event.Err)
+		connFailed = hermes.DidConnectionFail(event.Err)
+	}
+
+	h.collector.ObserveQuery(event.Op, event.Duration, class, connFailed)
+}
+
+func (h hook) BeginTx(ctx context.Context, _ *hermes.TxEvent) context.Context {
+	return ctx
+}
+
+func (h hook) EndTx(_ context.Context, event *hermes.TxEvent) {
+	h.collector.ObserveTx(event.Duration, event.Committed, event.MaxDepth)
+}
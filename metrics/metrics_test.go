@@ -0,0 +1,95 @@
+package metrics_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+	"github.com/sbowman/hermes/metrics"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := metrics.New()
+
+	mock := hermestest.New()
+	db := mock.DB(hermes.WithHooks(reg.Hook()))
+	defer db.Close()
+
+	reg.Register("primary", db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("insert into samples")
+	mock.ExpectExec("insert into samples").WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectExec("update samples").WillReturnError(&pq.Error{Code: "57P01"})
+	mock.ExpectExec("update samples").WillReturnError(&pq.Error{Code: "57014"})
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+
+	nested, err := tx.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start nested transaction: %s", err)
+	}
+
+	if _, err := nested.Exec("insert into samples values ('Bob')"); err != nil {
+		t.Fatalf("Unable to insert: %s", err)
+	}
+
+	if _, err := nested.Exec("insert into samples values ('Bob')"); err == nil {
+		t.Fatal("Expected a unique violation")
+	}
+
+	nested.Close()
+	tx.Close()
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Unable to commit: %s", err)
+	}
+	tx.Close()
+
+	// An admin shutdown is a connection failure; a canceled query isn't
+	for idx := 0; idx < 2; idx++ {
+		if _, err := db.Exec("update samples set name = 'Bob'"); err == nil {
+			t.Fatal("Expected the update to fail")
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := reg.WriteTo(&buf); err != nil {
+		t.Fatalf("Unable to write metrics: %s", err)
+	}
+
+	out := buf.String()
+	for _, expected := range []string{
+		`hermes_query_duration_seconds_count{op="Exec"} 4`,
+		`hermes_query_errors_total{op="Exec",class="23",connection_failure="false"} 1`,
+		`hermes_query_errors_total{op="Exec",class="57",connection_failure="false"} 1`,
+		`hermes_query_errors_total{op="Exec",class="57",connection_failure="true"} 1`,
+		`hermes_tx_duration_seconds_count{result="commit"} 1`,
+		`hermes_tx_duration_seconds_count{result="rollback"} 1`,
+		`hermes_tx_depth_bucket{le="0"} 1`,
+		`hermes_tx_depth_bucket{le="1"} 2`,
+		`hermes_pool_max_open_connections{db="primary"} 0`,
+	} {
+		if !strings.Contains(out, expected+"\n") {
+			t.Errorf("Expected %s in the metrics:\n%s", expected, out)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,325 @@
+package metrics
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sbowman/hermes"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the latency histograms.
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DepthBuckets are the upper bounds of the transaction depth histogram.
+var DepthBuckets = []float64{0, 1, 2, 3, 5, 8}
+
+// Registry is a Collector that keeps the measurements in memory and writes
+// them in the Prometheus text exposition format:
+//
+//	hermes_query_duration_seconds{op}          histogram of statement latency
+//	hermes_query_errors_total{op,class,        failed statements by SQLSTATE class,
+//	  connection_failure}                      and whether the connection failed
+//	hermes_tx_duration_seconds{result}         histogram of transaction duration,
+//	                                           where result is "commit" or "rollback"
+//	hermes_tx_depth                            histogram of the deepest nesting
+//	hermes_pool_*{db}                          connection pool statistics
+//
+// The _count of hermes_tx_duration_seconds is the number of commits or
+// rollbacks.  A Registry is an http.Handler that serves the metrics.
+type Registry struct {
+	buckets []float64
+
+	mu      sync.Mutex
+	queries map[string]*histogram // by operation
+	errors  map[errorKey]uint64   // by operation, class, and connection failure
+	txs     map[string]*histogram // by result
+	depth   *histogram
+	dbs     []pool
+}
+
+// Labels of a failed statement.
+type errorKey struct {
+	op, class  string
+	connFailed bool
+}
+
+// A database whose pool statistics are reported.
+type pool struct {
+	name string
+	db   *hermes.DB
+}
+
+// New creates a registry.  The latency histograms use the buckets, in
+// seconds, or DefaultBuckets if none are given.
+func New(buckets ...float64) *Registry {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	return &Registry{
+		buckets: sorted,
+		queries: make(map[string]*histogram),
+		errors:  make(map[errorKey]uint64),
+		txs:     make(map[string]*histogram),
+		depth:   newHistogram(DepthBuckets),
+	}
+}
+
+// Hook returns a hermes.Hook that records measurements in the registry.
+func (r *Registry) Hook() hermes.Hook {
+	return Hook(r)
+}
+
+// Register reports the connection pool statistics of the database, labeled
+// with the name.
+func (r *Registry) Register(name string, db *hermes.DB) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.dbs = append(r.dbs, pool{name, db})
+}
+
+// ObserveQuery records a statement.  See Collector.
+func (r *Registry) ObserveQuery(op string, duration time.Duration, class string, connFailed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.queries[op]
+	if !ok {
+		h = newHistogram(r.buckets)
+		r.queries[op] = h
+	}
+	h.observe(duration.Seconds())
+
+	if class != "" {
+		r.errors[errorKey{op, class, connFailed}]++
+	}
+}
+
+// ObserveTx records a transaction.  See Collector.
+func (r *Registry) ObserveTx(duration time.Duration, committed bool, depth int) {
+	result := "rollback"
+	if committed {
+		result = "commit"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.txs[result]
+	if !ok {
+		h = newHistogram(r.buckets)
+		r.txs[result] = h
+	}
+	h.observe(duration.Seconds())
+
+	r.depth.observe(float64(depth))
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: bufio.NewWriter(w)}
+
+	r.mu.Lock()
+	r.write(cw)
+	dbs := append([]pool(nil), r.dbs...)
+	r.mu.Unlock()
+
+	writePools(cw, dbs)
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+
+	return cw.n, cw.err
+}
+
+// Writes the query and transaction metrics.  Must hold the lock.
+func (r *Registry) write(w *countWriter) {
+	w.printf("# HELP hermes_query_duration_seconds Latency of statements executed by Hermes.\n")
+	w.printf("# TYPE hermes_query_duration_seconds histogram\n")
+	for _, op := range sortedKeys(r.queries) {
+		r.queries[op].write(w, "hermes_query_duration_seconds", label("op", op))
+	}
+
+	w.printf("# HELP hermes_query_errors_total Statements that failed, by SQLSTATE class and whether the connection failed.\n")
+	w.printf("# TYPE hermes_query_errors_total counter\n")
+
+	keys := make([]errorKey, 0, len(r.errors))
+	for key := range r.errors {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].op != keys[j].op {
+			return keys[i].op < keys[j].op
+		}
+		if keys[i].class != keys[j].class {
+			return keys[i].class < keys[j].class
+		}
+		return !keys[i].connFailed && keys[j].connFailed
+	})
+
+	for _, key := range keys {
+		w.printf("hermes_query_errors_total{%s,%s,%s} %d\n", label("op", key.op), label("class", key.class),
+			label("connection_failure", strconv.FormatBool(key.connFailed)), r.errors[key])
+	}
+
+	w.printf("# HELP hermes_tx_duration_seconds Duration of transactions, by result.\n")
+	w.printf("# TYPE hermes_tx_duration_seconds histogram\n")
+	for _, result := range sortedKeys(r.txs) {
+		r.txs[result].write(w, "hermes_tx_duration_seconds", label("result", result))
+	}
+
+	w.printf("# HELP hermes_tx_depth Deepest nesting of each transaction.\n")
+	w.printf("# TYPE hermes_tx_depth histogram\n")
+	r.depth.write(w, "hermes_tx_depth", "")
+}
+
+// Writes the connection pool statistics for each database.
+func writePools(w *countWriter, dbs []pool) {
+	if len(dbs) == 0 {
+		return
+	}
+
+	type gauge struct {
+		name, kind, help string
+		value            func(s sql.DBStats) float64
+	}
+
+	gauges := []gauge{
+		{"hermes_pool_max_open_connections", "gauge", "Maximum number of open connections to the database.",
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"hermes_pool_open_connections", "gauge", "Number of established connections, in use and idle.",
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"hermes_pool_in_use_connections", "gauge", "Number of connections currently in use.",
+			func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"hermes_pool_idle_connections", "gauge", "Number of idle connections.",
+			func(s sql.DBStats) float64 { return float64(s.Idle) }},
+		{"hermes_pool_wait_count_total", "counter", "Total number of connections waited for.",
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"hermes_pool_wait_duration_seconds_total", "counter", "Total time blocked waiting for a new connection.",
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"hermes_pool_max_idle_closed_total", "counter", "Total number of connections closed due to the idle limit.",
+			func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"hermes_pool_max_lifetime_closed_total", "counter", "Total number of connections closed due to the maximum lifetime.",
+			func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+
+	stats := make([]sql.DBStats, len(dbs))
+	for idx, p := range dbs {
+		stats[idx] = p.db.BaseDB().Stats()
+	}
+
+	for _, g := range gauges {
+		w.printf("# HELP %s %s\n", g.name, g.help)
+		w.printf("# TYPE %s %s\n", g.name, g.kind)
+
+		for idx, p := range dbs {
+			w.printf("%s{%s} %s\n", g.name, label("db", p.name), formatFloat(g.value(stats[idx])))
+		}
+	}
+}
+
+// A cumulative histogram.
+type histogram struct {
+	bounds []float64
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	h.count++
+	h.sum += v
+
+	for idx, bound := range h.bounds {
+		if v <= bound {
+			h.counts[idx]++
+			return
+		}
+	}
+}
+
+// Writes the histogram's buckets, sum, and count.  Labels are prepended to
+// the bucket's "le" label.
+func (h *histogram) write(w *countWriter, name, labels string) {
+	prefix := ""
+	if labels != "" {
+		prefix = labels + ","
+	}
+
+	var cumulative uint64
+	for idx, bound := range h.bounds {
+		cumulative += h.counts[idx]
+		w.printf("%s_bucket{%s%s} %d\n", name, prefix, label("le", formatFloat(bound)), cumulative)
+	}
+	w.printf("%s_bucket{%s%s} %d\n", name, prefix, label("le", "+Inf"), h.count)
+
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+
+	w.printf("%s_sum%s %s\n", name, labels, formatFloat(h.sum))
+	w.printf("%s_count%s %d\n", name, labels, h.count)
+}
+
+// Formats a label, escaping the value.
+func label(name, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return fmt.Sprintf(`%s="%s"`, name, value)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]*histogram) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Counts the bytes written and remembers the first error.
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) printf(format string, args ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
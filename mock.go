@@ -1,12 +1,17 @@
 package hermes
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime/debug"
+
 	"github.com/jmoiron/sqlx"
 )
 
-// Create a Mock Connection. This connection will ignore all calls to Commit
-// and always rollback on close
-func Mock(driverName, dataSourceName string, maxOpen, maxIdle int) (*MockDB, error) {
+// Mock creates a Mock Connection. This connection will ignore all calls to
+// Commit on the outermost transaction and always rollback on close.
+func Mock(driverName, dataSourceName string, maxOpen, maxIdle int, opts ...Option) (*MockDB, error) {
 	db, err := sqlx.Connect(driverName, dataSourceName)
 	if err != nil {
 		return nil, err
@@ -14,35 +19,177 @@ func Mock(driverName, dataSourceName string, maxOpen, maxIdle int) (*MockDB, err
 	db.SetMaxOpenConns(maxOpen)
 	db.SetMaxIdleConns(maxIdle)
 
-	return &MockDB{
-		&DB{
-			name: dataSourceName,
-			internal: db,
-		},
-	}, nil
+	return NewMock(NewDB(dataSourceName, db, nil, opts...)), nil
+}
+
+// NewMock wraps an existing database connection in a MockDB.
+func NewMock(db *DB) *MockDB {
+	return &MockDB{DB: db}
 }
 
-type MockDB struct {*DB}
+// MockDB wraps a database connection so every transaction it begins is a
+// MockTx, which never commits.
+type MockDB struct {
+	*DB
+
+	// NestedSavepoints, if true, backs the nested transactions of every
+	// MockTx with savepoints, regardless of the database's setting.
+	NestedSavepoints bool
+}
 
+// Begin a new mock transaction.
 func (db *MockDB) Begin() (Conn, error) {
-	c, err := db.DB.Begin()
-	if err != nil {
-		return nil, err
-	}
-	tx := c.(*Tx)
-	return &MockTx{tx}, nil
+	return db.mock(db.DB.Begin())
 }
 
-type MockTx struct {*Tx}
+// BeginCtx begins a new mock transaction in context.
+func (db *MockDB) BeginCtx(ctx context.Context) (Conn, error) {
+	return db.mock(db.DB.BeginCtx(ctx))
+}
+
+// BeginTx begins a new mock transaction in context with the given options.
+func (db *MockDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (Conn, error) {
+	return db.mock(db.DB.BeginTx(ctx, opts))
+}
+
+// MockCommit records a call to Commit on a MockTx.
+type MockCommit struct {
+	// Depth is the nesting depth of the transaction when Commit was
+	// called.
+	Depth int
+
+	// Suppressed is true if the commit was ignored, i.e. it was called on
+	// the outermost transaction.
+	Suppressed bool
+}
+
+// MockTx wraps a transaction, ignoring calls to Commit on the outermost
+// transaction and always rolling back when the outermost transaction is
+// closed.  Nested transactions begun from a MockTx return the MockTx, so the
+// code under test can never commit the database transaction.
+//
+// Because the outermost commit is ignored, OnCommit callbacks are never
+// called.
+type MockTx struct {
+	*Tx
+	commits []MockCommit
+	origins []string // stack traces of the open nested transactions
+}
 
-// ignore all commits
+// Begin a nested mock transaction.
+func (tx *MockTx) Begin() (Conn, error) {
+	return tx.nest(tx.Tx.Begin())
+}
+
+// BeginCtx begins a nested mock transaction in context.
+func (tx *MockTx) BeginCtx(ctx context.Context) (Conn, error) {
+	return tx.nest(tx.Tx.BeginCtx(ctx))
+}
+
+// BeginTx begins a nested mock transaction in context with the given options.
+func (tx *MockTx) BeginTx(ctx context.Context, opts *sql.TxOptions) (Conn, error) {
+	return tx.nest(tx.Tx.BeginTx(ctx, opts))
+}
+
+// Commit is ignored on the outermost transaction.  Nested transactions commit
+// normally, e.g. releasing their savepoint if NestedSavepoints is enabled.
 func (tx *MockTx) Commit() error {
-	return nil
+	depth := tx.Depth()
+	tx.commits = append(tx.commits, MockCommit{
+		Depth:      depth,
+		Suppressed: depth == 0,
+	})
+
+	if depth == 0 {
+		return nil
+	}
+
+	return tx.Tx.Commit()
 }
 
-// always rollback on close
+// Rollback the transaction.
+func (tx *MockTx) Rollback() error {
+	defer tx.closed()
+	return tx.Tx.Rollback()
+}
+
+// Close always rolls back the outermost transaction.  Nested transactions
+// close normally.
 func (tx *MockTx) Close() error {
+	defer tx.closed()
+
+	if tx.Depth() > 0 {
+		return tx.Tx.Close()
+	}
+
 	return tx.Tx.Rollback()
 }
 
+// OpenNested returns the stack traces of where each nested transaction that
+// hasn't been closed was begun, outermost first.  Useful for tracking down
+// code that leaks nested transactions.
+func (tx *MockTx) OpenNested() []string {
+	return tx.origins
+}
+
+// Commits returns the calls to Commit made on the transaction, in order.
+func (tx *MockTx) Commits() []MockCommit {
+	return tx.commits
+}
+
+// CommitCount returns the number of times Commit was called at the given
+// depth.
+func (tx *MockTx) CommitCount(depth int) int {
+	var count int
+	for _, c := range tx.commits {
+		if c.Depth == depth {
+			count++
+		}
+	}
+
+	return count
+}
+
+// ExpectCommits returns an error if Commit wasn't called exactly n times at
+// the given depth.  For example, ExpectCommits(0, 1) confirms the code under
+// test committed the outermost transaction exactly once.
+func (tx *MockTx) ExpectCommits(depth, n int) error {
+	if count := tx.CommitCount(depth); count != n {
+		return fmt.Errorf("expected %d commits at depth %d; got %d", n, depth, count)
+	}
+
+	return nil
+}
+
+// Wraps a new transaction in a MockTx.
+func (db *MockDB) mock(conn Conn, err error) (Conn, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	tx := conn.(*Tx)
+	if db.NestedSavepoints {
+		tx.nested = true
+	}
+
+	return &MockTx{Tx: tx}, nil
+}
+
+// Returns the mock transaction in place of the nested transaction, recording
+// where the nested transaction began.
+func (tx *MockTx) nest(_ Conn, err error) (Conn, error) {
+	if err != nil {
+		return nil, err
+	}
 
+	tx.origins = append(tx.origins, string(debug.Stack()))
+
+	return tx, nil
+}
+
+// Forgets the origins of nested transactions that have been closed.
+func (tx *MockTx) closed() {
+	if depth := tx.Depth(); depth < len(tx.origins) {
+		tx.origins = tx.origins[:depth]
+	}
+}
@@ -0,0 +1,80 @@
+package hermes_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+func TestMockCommits(t *testing.T) {
+	fake := hermestest.New()
+	db := hermes.NewMock(fake.DB())
+	defer db.Close()
+
+	fake.ExpectBegin()
+	fake.ExpectExec("insert into samples").WithArgs("Bob")
+	fake.ExpectRollback()
+
+	tx, err := db.BeginCtx(context.Background())
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+
+	mock, ok := tx.(*hermes.MockTx)
+	if !ok {
+		t.Fatalf("Expected a *hermes.MockTx; got %T", tx)
+	}
+
+	// The code under test...
+	err = func(conn hermes.Conn) error {
+		txn, err := conn.BeginCtx(context.Background())
+		if err != nil {
+			return err
+		}
+		defer txn.Close()
+
+		if _, ok := txn.(*hermes.MockTx); !ok {
+			t.Errorf("Expected the nested transaction to be a *hermes.MockTx; got %T", txn)
+		}
+
+		if _, err := txn.Exec("insert into samples values ($1)", "Bob"); err != nil {
+			return err
+		}
+
+		if err := txn.Commit(); err != nil {
+			return err
+		}
+
+		return nil
+	}(tx)
+
+	if err != nil {
+		t.Fatalf("Code under test failed: %s", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Errorf("Suppressed commit failed: %s", err)
+	}
+
+	if err := mock.ExpectCommits(1, 1); err != nil {
+		t.Error(err)
+	}
+
+	if err := mock.ExpectCommits(0, 1); err != nil {
+		t.Error(err)
+	}
+
+	if commits := mock.Commits(); !commits[1].Suppressed || commits[0].Suppressed {
+		t.Errorf("Expected only the outermost commit to be suppressed: %+v", commits)
+	}
+
+	if err := tx.Close(); err != nil {
+		t.Errorf("Unable to close transaction: %s", err)
+	}
+
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,14 @@
+module github.com/sbowman/hermes/otel
+
+go 1.16
+
+require (
+	github.com/sbowman/hermes v1.3.0
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/sdk v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
+)
+
+// Builds against the hermes checkout for local development.  Ignored by
+// modules that import this one, which get the version required above.
+replace github.com/sbowman/hermes => ../
@@ -0,0 +1,81 @@
+// Package otel adapts an OpenTelemetry tracer for Hermes, so each transaction
+// and statement appears as a span in your traces.
+//
+//	db, err := hermes.Connect("postgres", URI, 10, 2,
+//	    hermes.WithTracer(otel.New(otel.Tracer())))
+//
+// The package is a separate module, so applications that don't use
+// OpenTelemetry don't depend on it.
+package otel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sbowman/hermes"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName identifies Hermes as the source of the spans.
+const InstrumentationName = "github.com/sbowman/hermes"
+
+// Tracer returns a tracer for Hermes from the global OpenTelemetry tracer
+// provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// New adapts the OpenTelemetry tracer to a hermes.Tracer.  Statement spans are
+// client spans.
+func New(tracer trace.Tracer) hermes.Tracer {
+	return &tracerAdapter{tracer}
+}
+
+type tracerAdapter struct {
+	tracer trace.Tracer
+}
+
+func (t *tracerAdapter) Start(ctx context.Context, name string) (context.Context, hermes.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, spanAdapter{span}
+}
+
+type spanAdapter struct {
+	span trace.Span
+}
+
+func (s spanAdapter) SetAttribute(key string, value interface{}) {
+	s.span.SetAttributes(attr(key, value))
+}
+
+func (s spanAdapter) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s spanAdapter) End() {
+	s.span.End()
+}
+
+// Converts the value to an OpenTelemetry attribute of the matching type.
+func attr(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case uint64:
+		return attribute.Int64(key, int64(v))
+	case float64:
+		return attribute.Float64(key, v)
+	}
+
+	return attribute.String(key, fmt.Sprint(value))
+}
@@ -0,0 +1,72 @@
+package otel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+	"github.com/sbowman/hermes/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	mock := hermestest.New()
+	db := mock.DB(hermes.WithTracer(otel.New(provider.Tracer(otel.InstrumentationName))))
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("update samples").WillReturnResult(0, 2)
+	mock.ExpectExec("delete from samples").WillReturnError(errors.New("oops"))
+	mock.ExpectRollback()
+
+	tx, err := db.BeginCtx(context.Background())
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+
+	if _, err := tx.Exec("update samples set name = 'Bob'"); err != nil {
+		t.Fatalf("Unable to update: %s", err)
+	}
+
+	_, _ = tx.Exec("delete from samples")
+
+	if err := tx.Close(); err != nil {
+		t.Fatalf("Unable to close transaction: %s", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected three spans; got %d", len(spans))
+	}
+
+	update, del, txSpan := spans[0], spans[1], spans[2]
+
+	if txSpan.Name() != "hermes.Tx" {
+		t.Errorf("Expected the transaction span last; got %s", txSpan.Name())
+	}
+
+	if update.Parent().SpanID() != txSpan.SpanContext().SpanID() {
+		t.Error("Expected the statement span to be a child of the transaction span")
+	}
+
+	attrs := attribute.NewSet(update.Attributes()...)
+	if v, _ := attrs.Value(hermes.AttrDBStatement); v.AsString() != "update samples set name = 'Bob'" {
+		t.Errorf("Unexpected statement %q", v.AsString())
+	}
+
+	if v, _ := attrs.Value(hermes.AttrRowsAffected); v.AsInt64() != 2 {
+		t.Errorf("Expected two rows affected; got %d", v.AsInt64())
+	}
+
+	if del.Status().Code != codes.Error {
+		t.Errorf("Expected the failed statement to have an error status; got %v", del.Status())
+	}
+}
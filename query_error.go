@@ -0,0 +1,99 @@
+package hermes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// QueryError wraps an error returned by the database with the statement that
+// caused it.  Unwraps to the original error, so errors.Is, errors.As, and
+// functions such as DidConnectionFail and ErrorClass see the driver's error:
+//
+//	var qe *hermes.QueryError
+//	if errors.As(err, &qe) {
+//	    log.Printf("%s failed at %s: %s", qe.Op, qe.Caller, qe.Query)
+//	}
+//
+// sql.ErrNoRows is never wrapped, so comparing against it directly still
+// works.
+type QueryError struct {
+	// Op is the Conn function that executed the statement, e.g. OpExec.
+	Op string
+
+	// Query is the text of the statement.
+	Query string
+
+	// Args are the types of the arguments passed with the statement.  The
+	// values are redacted, so the error may be logged safely.
+	Args []string
+
+	// InTx is true if the statement was executed in a transaction.
+	InTx bool
+
+	// Depth is the nesting depth of the transaction, zero for the outermost
+	// transaction or outside of a transaction.
+	Depth int
+
+	// Duration is how long the statement took to fail.
+	Duration time.Duration
+
+	// Caller is the file and line of the code that called Hermes.
+	Caller string
+
+	// Err is the error returned by the database driver.
+	Err error
+}
+
+// Error returns the operation, the query, and the original error message.
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s %q: %s", e.Op, e.Query, e.Err)
+}
+
+// Unwrap returns the original error.
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// Wraps the error returned by a statement in a QueryError.
+func wrapQueryError(op, query string, args []interface{}, tx *Tx, start time.Time, err error) error {
+	if err == nil || errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(2, pcs)
+
+	qe := &QueryError{
+		Op:       op,
+		Query:    query,
+		Args:     redact(args),
+		Duration: time.Since(start),
+		Caller:   origin(pcs[:n]),
+		Err:      err,
+	}
+
+	if tx != nil {
+		qe.InTx = true
+		qe.Depth = tx.Depth()
+	}
+
+	return qe
+}
+
+// Replaces argument values with their types, so errors and logs don't leak
+// sensitive data.
+func redact(args []interface{}) []string {
+	types := make([]string, len(args))
+	for idx, arg := range args {
+		if arg == nil {
+			types[idx] = "nil"
+		} else {
+			types[idx] = fmt.Sprintf("%T", arg)
+		}
+	}
+
+	return types
+}
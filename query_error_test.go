@@ -0,0 +1,86 @@
+package hermes_test
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+func TestQueryError(t *testing.T) {
+	fake := hermestest.New()
+	db := fake.DB()
+	defer db.Close()
+
+	fake.ExpectBegin()
+	fake.ExpectExec("update samples").WithArgs("secret", int64(3)).WillReturnError(&pq.Error{Code: "08006", Message: "connection failure"})
+	fake.ExpectRollback()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+	defer tx.Close()
+
+	nested, err := tx.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start nested transaction: %s", err)
+	}
+	defer nested.Close()
+
+	_, err = nested.Exec("update samples set name = $1 where id = $2", "secret", int64(3))
+	if err == nil {
+		t.Fatal("Expected the update to fail")
+	}
+
+	var qe *hermes.QueryError
+	if !errors.As(err, &qe) {
+		t.Fatalf("Expected a QueryError; got %T", err)
+	}
+
+	if qe.Op != hermes.OpExec || qe.Query != "update samples set name = $1 where id = $2" {
+		t.Errorf("Unexpected statement %s %q", qe.Op, qe.Query)
+	}
+
+	if strings.Join(qe.Args, ", ") != "string, int64" {
+		t.Errorf("Expected redacted args; got %v", qe.Args)
+	}
+
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("Arguments should be redacted: %s", err)
+	}
+
+	if !qe.InTx || qe.Depth != 1 {
+		t.Errorf("Expected a nested transaction at depth 1; got %v, %d", qe.InTx, qe.Depth)
+	}
+
+	if !strings.Contains(qe.Caller, "query_error_test.go") {
+		t.Errorf("Expected the caller to be the test; got %s", qe.Caller)
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "08006" {
+		t.Errorf("Expected to unwrap to the driver error; got %v", err)
+	}
+
+	if !hermes.DidConnectionFail(err) {
+		t.Error("Expected a connection failure")
+	}
+}
+
+func TestQueryErrorNoRows(t *testing.T) {
+	fake := hermestest.New()
+	db := fake.DB()
+	defer db.Close()
+
+	fake.ExpectQuery("select name from samples").WillReturnRows(hermestest.NewRows("name"))
+
+	var name string
+	if err := db.Get(&name, "select name from samples where id = $1", 1); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, unwrapped; got %v", err)
+	}
+}
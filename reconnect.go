@@ -0,0 +1,214 @@
+package hermes
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrCannotReconnect returned by Reconnect if the database wasn't created by
+// Connect, ConnectUnchecked, or ConnectFailover, so Hermes doesn't know the
+// driver and data source to reconnect with.
+var ErrCannotReconnect = errors.New("database has no driver and data source to reconnect with")
+
+// Coalesces concurrent reconnects into one.
+type reconnects struct {
+	mu      sync.Mutex
+	pending *reconnectCall
+}
+
+// A reconnect in progress.  Callers wait on done for the result.
+type reconnectCall struct {
+	force   bool // replace the pool even if the primary hasn't moved
+	done    chan struct{}
+	swapped bool // the pool was replaced
+	err     error
+}
+
+// Counts the transactions open on a connection pool, so a replaced pool can be
+// closed once they finish.
+type poolRefs struct {
+	txs     int
+	retired bool // close the pool once the transactions finish
+}
+
+// Reconnect replaces the database connection pool with a fresh one, built from
+// the driver and data source name the database was connected with.  For a
+// database connected with ConnectFailover, reconnects to the current primary.
+// The old pool stops taking new work, and is closed once the transactions open
+// on it finish.
+//
+// Safe to call from multiple goroutines, e.g. from an OnFailure function:
+// calls made while a reconnect is in progress wait for it and share its
+// result.  If the new pool can't connect, keeps the old pool and returns the
+// error.
+func (db *DB) Reconnect() error {
+	if db.driver == "" {
+		return ErrCannotReconnect
+	}
+
+	_, err := db.reconnect(true)
+	return err
+}
+
+// Replaces the connection pool, returning true if it was replaced.  If force is
+// false, only replaces the pool if the primary has moved.  If a reconnect is
+// already in progress, waits for it to finish and returns its result; a forced
+// reconnect waiting on an unforced one starts over if the pool wasn't replaced.
+func (db *DB) reconnect(force bool) (bool, error) {
+	for {
+		db.reconnects.mu.Lock()
+		call := db.reconnects.pending
+
+		if call == nil {
+			call = &reconnectCall{force: force, done: make(chan struct{})}
+			db.reconnects.pending = call
+			db.reconnects.mu.Unlock()
+
+			call.swapped, call.err = db.replacePool(force)
+
+			db.reconnects.mu.Lock()
+			db.reconnects.pending = nil
+			db.reconnects.mu.Unlock()
+
+			close(call.done)
+			return call.swapped, call.err
+		}
+
+		db.reconnects.mu.Unlock()
+		<-call.done
+
+		if call.force || !force || call.swapped {
+			return call.swapped, call.err
+		}
+	}
+}
+
+// Connects a new pool, to the primary if the database can fail over, and swaps
+// it in.  The old pool is closed once its transactions finish.  Returns true if
+// the pool was replaced.
+func (db *DB) replacePool(force bool) (bool, error) {
+	db.poolMu.RLock()
+	cfg, current := db.config, db.name
+	db.poolMu.RUnlock()
+
+	var (
+		internal *sqlx.DB
+		dsn      = current
+		err      error
+	)
+
+	if db.canFailover() {
+		internal, dsn, err = findPrimary(db.driver, db.dsns, &cfg)
+	} else {
+		internal, err = open(db.driver, dsn, cfg.MaxOpen, cfg.MaxIdle)
+		if err == nil {
+			cfg.configure(internal)
+		}
+	}
+
+	if err != nil {
+		db.logger().Log(LogError, "Unable to reconnect to the database", "err", err)
+		return false, err
+	}
+
+	if dsn == current && !force {
+		return false, internal.Close()
+	}
+
+	old := db.swap(dsn, internal)
+	if old == internal {
+		return false, db.retire(internal)
+	}
+
+	if dsn != current {
+		db.logger().Log(LogWarn, "Database failed over to a new primary")
+	} else {
+		db.logger().Log(LogInfo, "Reconnected to the database")
+	}
+
+	if db.monitoring() {
+		db.setHealthy(true)
+	}
+
+	return true, db.retire(old)
+}
+
+// Replaces the connection pool, returning the old one.  If the database has
+// been closed, leaves it alone and returns the new pool instead, to be
+// retired.
+func (db *DB) swap(name string, internal *sqlx.DB) *sqlx.DB {
+	db.poolMu.Lock()
+	defer db.poolMu.Unlock()
+
+	if db.closed {
+		return internal
+	}
+
+	old := db.internal
+	db.name = name
+	db.internal = internal
+
+	return old
+}
+
+// Returns the current connection pool for a new transaction, counting the
+// transaction against the pool.  Call release when the transaction finishes.
+func (db *DB) acquire() *sqlx.DB {
+	db.poolMu.Lock()
+	defer db.poolMu.Unlock()
+
+	refs := db.refs[db.internal]
+	if refs == nil {
+		if db.refs == nil {
+			db.refs = make(map[*sqlx.DB]*poolRefs)
+		}
+
+		refs = &poolRefs{}
+		db.refs[db.internal] = refs
+	}
+
+	refs.txs++
+	return db.internal
+}
+
+// Releases a transaction's hold on its connection pool.  If the pool has been
+// replaced and this was its last transaction, closes the pool.
+func (db *DB) release(internal *sqlx.DB) {
+	db.poolMu.Lock()
+
+	refs := db.refs[internal]
+	if refs == nil {
+		db.poolMu.Unlock()
+		return
+	}
+
+	refs.txs--
+	if refs.txs > 0 {
+		db.poolMu.Unlock()
+		return
+	}
+
+	delete(db.refs, internal)
+	db.poolMu.Unlock()
+
+	if refs.retired {
+		_ = internal.Close()
+	}
+}
+
+// Closes a replaced connection pool once its open transactions finish.
+// Returns the error from closing the pool if it closes immediately.
+func (db *DB) retire(internal *sqlx.DB) error {
+	db.poolMu.Lock()
+
+	if refs := db.refs[internal]; refs != nil && refs.txs > 0 {
+		refs.retired = true
+		db.poolMu.Unlock()
+		return nil
+	}
+
+	db.poolMu.Unlock()
+	return internal.Close()
+}
@@ -0,0 +1,100 @@
+package hermes_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+func TestReconnect(t *testing.T) {
+	mock := hermestest.New()
+
+	db, err := hermes.Connect(hermestest.DriverName, mock.DataSourceName(), 5, 1)
+	if err != nil {
+		t.Fatalf("Unable to connect: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+	defer tx.Close()
+
+	old := db.BaseDB()
+
+	if err := db.Reconnect(); err != nil {
+		t.Fatalf("Unable to reconnect: %s", err)
+	}
+
+	if db.BaseDB() == old {
+		t.Fatal("Expected a new connection pool")
+	}
+
+	if tx.BaseDB() != old {
+		t.Error("Expected the transaction to keep its connection pool")
+	}
+
+	if err := old.Ping(); err != nil {
+		t.Errorf("Expected the old pool to stay open until the transaction finishes: %s", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Unable to commit: %s", err)
+	}
+
+	if err := old.Ping(); err == nil {
+		t.Error("Expected the old pool to close once the transaction finished")
+	}
+
+	if err := db.Ping(); err != nil {
+		t.Errorf("Unable to ping the new pool: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReconnectConcurrent(t *testing.T) {
+	mock := hermestest.New()
+
+	db, err := hermes.Connect(hermestest.DriverName, mock.DataSourceName(), 5, 1)
+	if err != nil {
+		t.Fatalf("Unable to connect: %s", err)
+	}
+	defer db.Close()
+
+	var wg sync.WaitGroup
+	for idx := 0; idx < 10; idx++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := db.Reconnect(); err != nil {
+				t.Errorf("Unable to reconnect: %s", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if err := db.Ping(); err != nil {
+		t.Errorf("Unable to ping after reconnecting: %s", err)
+	}
+}
+
+func TestReconnectUnknownDriver(t *testing.T) {
+	db := hermestest.New().DB()
+	defer db.Close()
+
+	if err := db.Reconnect(); err != hermes.ErrCannotReconnect {
+		t.Errorf(`Expected error "%s"; got "%v"`, hermes.ErrCannotReconnect, err)
+	}
+}
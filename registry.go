@@ -0,0 +1,223 @@
+package hermes
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TxInfo describes a transaction that has begun but not yet committed or
+// rolled back.
+type TxInfo struct {
+	// ID uniquely identifies the transaction for the life of the database
+	// connection.
+	ID uint64
+
+	// Started is when the transaction began.
+	Started time.Time
+
+	// Age is how long the transaction has been open.
+	Age time.Duration
+
+	// Depth is the nesting depth of the transaction; 0 is the outermost
+	// transaction.
+	Depth int
+
+	// LastQuery is the most recent statement executed in the transaction,
+	// if any.  Only recorded with transaction details; see
+	// Config.TxRegistry.
+	LastQuery string
+
+	// Stack is the stack trace of where the transaction began.  Only
+	// recorded with transaction details; see Config.TxRegistry.
+	Stack string
+}
+
+// String formats the transaction info for reporting, including the stack
+// trace.
+func (info TxInfo) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Transaction %d open for %s at depth %d", info.ID, info.Age.Round(time.Millisecond), info.Depth)
+	if info.LastQuery != "" {
+		fmt.Fprintf(&b, "; last query: %s", info.LastQuery)
+	}
+
+	b.WriteString("\n")
+	b.WriteString(info.Stack)
+
+	return b.String()
+}
+
+// OpenTransactions returns the transactions begun on the database that haven't
+// yet committed or rolled back, oldest first.  Use it to track down
+// transactions that were never closed.  The stack trace and last query are
+// only recorded if the database is configured WithTxRegistry or
+// WithSlowQueryLog, or the transaction timer is enabled.
+func (db *DB) OpenTransactions() []TxInfo {
+	db.txs.mu.Lock()
+	records := make([]*txRecord, 0, len(db.txs.open))
+	for _, rec := range db.txs.open {
+		records = append(records, rec)
+	}
+	db.txs.mu.Unlock()
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].id < records[j].id
+	})
+
+	now := time.Now()
+	infos := make([]TxInfo, len(records))
+	for idx, rec := range records {
+		infos[idx] = rec.info(now)
+	}
+
+	return infos
+}
+
+// DumpTransactions writes the open transactions to w, oldest first.  See
+// OpenTransactions.
+func (db *DB) DumpTransactions(w io.Writer) error {
+	for _, info := range db.OpenTransactions() {
+		if _, err := fmt.Fprintf(w, "%s\n", info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Tracks the open transactions on a database.
+type txRegistry struct {
+	mu     sync.Mutex
+	nextID uint64
+	open   map[uint64]*txRecord
+}
+
+// Records an open transaction.  Safe to read from other goroutines while the
+// transaction is in use.
+type txRecord struct {
+	id      uint64
+	started time.Time
+	stack   []uintptr
+	details bool // record the last query; see Config.TxRegistry
+
+	mu    sync.Mutex
+	depth int
+	query string
+}
+
+// Registers a new transaction.  If the database records transaction details,
+// records the stack trace of the caller, skipping the given number of frames
+// beyond track itself.
+func (db *DB) track(skip int) *txRecord {
+	rec := &txRecord{
+		started: time.Now(),
+		details: db.config.TxRegistry || db.config.SlowQuery > 0 || db.timeouts().Enabled,
+	}
+
+	if rec.details {
+		pcs := make([]uintptr, 32)
+		n := runtime.Callers(skip+2, pcs)
+		rec.stack = pcs[:n]
+	}
+
+	db.txs.mu.Lock()
+	defer db.txs.mu.Unlock()
+
+	db.txs.nextID++
+	rec.id = db.txs.nextID
+
+	if db.txs.open == nil {
+		db.txs.open = make(map[uint64]*txRecord)
+	}
+	db.txs.open[rec.id] = rec
+
+	return rec
+}
+
+// Removes the transaction from the registry once it has committed or rolled
+// back.
+func (db *DB) untrack(rec *txRecord) {
+	db.txs.mu.Lock()
+	delete(db.txs.open, rec.id)
+	db.txs.mu.Unlock()
+}
+
+func (rec *txRecord) setDepth(depth int) {
+	rec.mu.Lock()
+	rec.depth = depth
+	rec.mu.Unlock()
+}
+
+func (rec *txRecord) setQuery(query string) {
+	if !rec.details {
+		return
+	}
+
+	rec.mu.Lock()
+	rec.query = query
+	rec.mu.Unlock()
+}
+
+// Returns the file and line of the first function outside of Hermes in the
+// stack, i.e. where the application began the transaction.  Returns an empty
+// string if the stack wasn't recorded.
+func (rec *txRecord) origin() string {
+	return origin(rec.stack)
+}
+
+// Returns the file and line of the first function outside of Hermes in the
+// stack trace.
+func origin(pcs []uintptr) string {
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" && !strings.HasPrefix(frame.Function, "github.com/sbowman/hermes.") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+
+		if !more {
+			return ""
+		}
+	}
+}
+
+// Returns a snapshot of the transaction.
+func (rec *txRecord) info(now time.Time) TxInfo {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return TxInfo{
+		ID:        rec.id,
+		Started:   rec.started,
+		Age:       now.Sub(rec.started),
+		Depth:     rec.depth,
+		LastQuery: rec.query,
+		Stack:     formatStack(rec.stack),
+	}
+}
+
+// Formats the stack trace similar to a panic:  the function, followed by the
+// file and line on an indented line.
+func formatStack(pcs []uintptr) string {
+	var b strings.Builder
+
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return b.String()
+}
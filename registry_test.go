@@ -0,0 +1,107 @@
+package hermes_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+func TestOpenTransactions(t *testing.T) {
+	fake := hermestest.New()
+	db := fake.DB(hermes.WithTxRegistry())
+	defer db.Close()
+
+	fake.ExpectBegin()
+	fake.ExpectExec("update samples")
+	fake.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+	defer tx.Close()
+
+	nested, err := tx.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start nested transaction: %s", err)
+	}
+
+	if _, err := nested.Exec("update samples set name = 'Bob'"); err != nil {
+		t.Fatalf("Unable to update: %s", err)
+	}
+
+	open := db.OpenTransactions()
+	if len(open) != 1 {
+		t.Fatalf("Expected one open transaction; got %d", len(open))
+	}
+
+	info := open[0]
+	if info.Depth != 1 {
+		t.Errorf("Expected depth 1; got %d", info.Depth)
+	}
+
+	if info.LastQuery != "update samples set name = 'Bob'" {
+		t.Errorf("Unexpected last query %q", info.LastQuery)
+	}
+
+	if !strings.Contains(info.Stack, "TestOpenTransactions") {
+		t.Errorf("Expected the stack to include the test; got\n%s", info.Stack)
+	}
+
+	var buf bytes.Buffer
+	if err := db.DumpTransactions(&buf); err != nil {
+		t.Fatalf("Unable to dump transactions: %s", err)
+	}
+
+	if !strings.Contains(buf.String(), "at depth 1; last query: update samples") {
+		t.Errorf("Unexpected dump:\n%s", buf.String())
+	}
+
+	if err := nested.Commit(); err != nil {
+		t.Errorf("Unable to commit nested transaction: %s", err)
+	}
+
+	if err := nested.Close(); err != nil {
+		t.Errorf("Unable to close nested transaction: %s", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Errorf("Unable to commit: %s", err)
+	}
+
+	if open := db.OpenTransactions(); len(open) != 0 {
+		t.Errorf("Expected no open transactions after commit; got %d", len(open))
+	}
+}
+
+func TestOpenTransactionsWithoutDetails(t *testing.T) {
+	fake := hermestest.New()
+	db := fake.DB()
+	defer db.Close()
+
+	fake.ExpectBegin()
+	fake.ExpectExec("update samples")
+	fake.ExpectRollback()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+	defer tx.Close()
+
+	if _, err := tx.Exec("update samples set name = 'Bob'"); err != nil {
+		t.Fatalf("Unable to update: %s", err)
+	}
+
+	open := db.OpenTransactions()
+	if len(open) != 1 {
+		t.Fatalf("Expected one open transaction; got %d", len(open))
+	}
+
+	if open[0].LastQuery != "" || open[0].Stack != "" {
+		t.Errorf("Expected no stack or last query without WithTxRegistry; got %q\n%s", open[0].LastQuery, open[0].Stack)
+	}
+}
@@ -0,0 +1,314 @@
+package hermes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrInvalidLSN returned by ParseLSN if the text isn't a PostgreSQL log
+	// sequence number, e.g. "16/B374D848".
+	ErrInvalidLSN = errors.New("invalid log sequence number")
+
+	// ErrNotReplica returned when checking the lag of a replica that isn't
+	// in recovery, i.e. isn't replicating from a primary.
+	ErrNotReplica = errors.New("database is not a replica")
+)
+
+// Measures how far a replica is behind.  The lag is zero if the replica has
+// replayed everything it received, so an idle primary doesn't look like a
+// lagging replica.
+const lagQuery = `SELECT pg_last_wal_replay_lsn()::text,
+	CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END`
+
+// Returns the primary's current position in the write-ahead log.
+const positionQuery = "SELECT pg_current_wal_lsn()::text"
+
+// How long to wait to record the primary's position after a write.
+const positionTimeout = 5 * time.Second
+
+// How long a read in a session waits, in total, to check the replicas'
+// positions when the lag monitor isn't running.
+const positionCheckTimeout = time.Second
+
+// How long to skip checking the position of a replica after a check fails.
+const positionCheckBackoff = 5 * time.Second
+
+// LSN is a PostgreSQL log sequence number, a position in the write-ahead log.
+type LSN uint64
+
+// ParseLSN parses the text form of a log sequence number, e.g. "16/B374D848".
+func ParseLSN(text string) (LSN, error) {
+	parts := strings.Split(text, "/")
+	if len(parts) != 2 {
+		return 0, ErrInvalidLSN
+	}
+
+	hi, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return 0, ErrInvalidLSN
+	}
+
+	lo, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return 0, ErrInvalidLSN
+	}
+
+	return LSN(hi<<32 | lo), nil
+}
+
+// String returns the log sequence number in PostgreSQL's text form.
+func (lsn LSN) String() string {
+	return fmt.Sprintf("%X/%X", uint64(lsn)>>32, uint64(lsn)&0xFFFFFFFF)
+}
+
+// Session tracks the position of the last write made through a Cluster, so
+// later reads in the session only go to replicas that have caught up to it.
+// Attach a session to a context with WithSession.  The zero value is an empty
+// session, ready to use.  Safe for use by multiple goroutines.
+//
+// To carry a session across requests, e.g. in a cookie, save the LSN and
+// restore it with Advance.
+type Session struct {
+	lsn uint64 // access atomically
+}
+
+type sessionKey struct{}
+
+// WithSession returns a context that reads your own writes.  Commits and Execs
+// through a Cluster with the context record the primary's position in the
+// session; reads with the context skip replicas that haven't replayed up to
+// that position, falling back to the primary.
+//
+// With the lag monitor running (see WithReplicaLag), reads compare the
+// session's position to each replica's position as of the last check.
+// Without it, a replica that doesn't appear to have caught up is queried for
+// its position before the read passes it over.  The read waits up to a second
+// in total for these checks, and skips replicas whose check recently failed.
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// Returns the session attached to the context, or nil.
+func sessionFrom(ctx context.Context) *Session {
+	if ctx == nil {
+		return nil
+	}
+
+	session, _ := ctx.Value(sessionKey{}).(*Session)
+	return session
+}
+
+// LSN returns the position of the last write in the session, or zero if the
+// session hasn't written anything.
+func (s *Session) LSN() LSN {
+	return LSN(atomic.LoadUint64(&s.lsn))
+}
+
+// Advance moves the session's position forward to the log sequence number.
+// Does nothing if the session is already past it.
+func (s *Session) Advance(lsn LSN) {
+	for {
+		current := atomic.LoadUint64(&s.lsn)
+		if uint64(lsn) <= current || atomic.CompareAndSwapUint64(&s.lsn, current, uint64(lsn)) {
+			return
+		}
+	}
+}
+
+// WithReplicaLag checks how far each replica in a Cluster is behind the
+// primary on the interval.  Replicas lagging more than the max are excluded
+// from reads until they catch up; if max is zero, the lag is tracked but
+// doesn't exclude replicas.  The lag monitor also tracks each replica's
+// position for sessions; see WithSession.
+//
+// Configure the lag monitor on the Cluster's primary database.
+func WithReplicaLag(interval, max time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.ReplicaLagInterval = interval
+		cfg.MaxReplicaLag = max
+	}
+}
+
+// A replica database in a cluster, with its lag as of the last check.
+type replica struct {
+	lag    uint64 // time behind the primary in nanoseconds; access atomically
+	lsn    uint64 // position replayed; access atomically
+	failed int64  // last failed position check in Unix nanos; access atomically
+	db     *DB
+}
+
+// Returns true if the replica is within the max lag, if any, and has replayed
+// the log up to the position.
+func (r *replica) caughtUp(maxLag time.Duration, position uint64) bool {
+	if maxLag > 0 && time.Duration(atomic.LoadUint64(&r.lag)) > maxLag {
+		return false
+	}
+
+	return atomic.LoadUint64(&r.lsn) >= position
+}
+
+// Returns true if the replica has caught up to the session's position.
+// Without the lag monitor, the replica's position is only as current as the
+// last check, so if given a context for checking positions, checks it again
+// rather than passing over a replica that may have caught up since.  Skips
+// the check if the context is done or the replica's last check recently
+// failed.
+func (c *Cluster) caughtUp(check context.Context, r *replica, position uint64) bool {
+	if r.caughtUp(c.maxLag, position) {
+		return true
+	}
+
+	if check == nil || check.Err() != nil {
+		return false
+	}
+
+	if failed := atomic.LoadInt64(&r.failed); failed != 0 && time.Since(time.Unix(0, failed)) < positionCheckBackoff {
+		return false
+	}
+
+	if err := r.check(check); err != nil {
+		atomic.StoreInt64(&r.failed, time.Now().UnixNano())
+		c.primary.logger().Log(LogDebug, "Unable to check replica position", "err", err)
+		return false
+	}
+
+	return r.caughtUp(c.maxLag, position)
+}
+
+// Queries the replica for its lag and position.
+func (r *replica) check(ctx context.Context) error {
+	var (
+		position sql.NullString
+		lag      sql.NullFloat64
+	)
+
+	if err := r.db.base().QueryRowxContext(ctx, lagQuery).Scan(&position, &lag); err != nil {
+		return err
+	}
+
+	if !position.Valid {
+		return ErrNotReplica
+	}
+
+	lsn, err := ParseLSN(position.String)
+	if err != nil {
+		return err
+	}
+
+	// If the replica's clock is ahead of the primary's, the lag may come
+	// back negative
+	seconds := lag.Float64
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	atomic.StoreUint64(&r.lsn, uint64(lsn))
+	atomic.StoreUint64(&r.lag, uint64(seconds*float64(time.Second)))
+
+	return nil
+}
+
+// CheckLag queries each replica for its lag and position now, rather than
+// waiting on the lag monitor.  Checks every replica, returning the first
+// error.
+func (c *Cluster) CheckLag(ctx context.Context) error {
+	var first error
+
+	for _, r := range c.replicas {
+		if err := r.check(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// ReplicaLag returns how far each replica was behind the primary at the last
+// check, in the same order as Replicas.
+func (c *Cluster) ReplicaLag() []time.Duration {
+	lags := make([]time.Duration, len(c.replicas))
+	for idx, r := range c.replicas {
+		lags[idx] = time.Duration(atomic.LoadUint64(&r.lag))
+	}
+
+	return lags
+}
+
+// Starts the background lag monitor.
+func (c *Cluster) startLagMonitor(interval time.Duration) {
+	c.done = make(chan struct{})
+	go c.monitorLag(interval)
+}
+
+// Stops the background lag monitor, if it's running.
+func (c *Cluster) stopLagMonitor() {
+	if c.done == nil {
+		return
+	}
+
+	c.stopOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// Checks the replicas' lag on the interval.
+func (c *Cluster) monitorLag(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := c.CheckLag(ctx); err != nil {
+			c.primary.logger().Log(LogDebug, "Unable to check replica lag", "err", err)
+		}
+		cancel()
+	}
+}
+
+// Registers a callback to record the primary's position in the session once
+// the transaction commits.
+func (c *Cluster) trackCommit(ctx context.Context, tx Conn) {
+	session := sessionFrom(ctx)
+	if session == nil {
+		return
+	}
+
+	tx.OnCommit(func() {
+		c.recordWrite(session)
+	})
+}
+
+// Records the primary's current position in the session.  If the position
+// can't be read, logs a warning; later reads in the session may be stale.
+func (c *Cluster) recordWrite(session *Session) {
+	ctx, cancel := context.WithTimeout(context.Background(), positionTimeout)
+	defer cancel()
+
+	var position string
+	if err := c.primary.base().QueryRowxContext(ctx, positionQuery).Scan(&position); err != nil {
+		c.primary.logger().Log(LogWarn, "Unable to record the write position", "err", err)
+		return
+	}
+
+	lsn, err := ParseLSN(position)
+	if err != nil {
+		c.primary.logger().Log(LogWarn, "Unable to record the write position", "err", err)
+		return
+	}
+
+	session.Advance(lsn)
+}
@@ -0,0 +1,242 @@
+package hermes_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+func TestParseLSN(t *testing.T) {
+	lsn, err := hermes.ParseLSN("16/B374D848")
+	if err != nil {
+		t.Fatalf("Unable to parse LSN: %s", err)
+	}
+
+	if lsn != 0x16B374D848 {
+		t.Errorf("Expected 0x16B374D848; got %#x", uint64(lsn))
+	}
+
+	if lsn.String() != "16/B374D848" {
+		t.Errorf("Expected 16/B374D848; got %s", lsn)
+	}
+
+	for _, text := range []string{"", "16", "16/", "XYZ/1", "1/2/3"} {
+		if _, err := hermes.ParseLSN(text); err != hermes.ErrInvalidLSN {
+			t.Errorf("Expected %q to be invalid; got %v", text, err)
+		}
+	}
+}
+
+// Returns the rows for a replica lag check.
+func lagRows(lsn string, lag float64) *hermestest.Rows {
+	return hermestest.NewRows("lsn", "lag").AddRow(lsn, lag)
+}
+
+func TestReplicaLag(t *testing.T) {
+	primary, replica1, replica2 := hermestest.New(), hermestest.New(), hermestest.New()
+
+	cluster := hermes.NewCluster(primary.DB(hermes.WithReplicaLag(time.Hour, time.Second)), replica1.DB(), replica2.DB())
+	defer cluster.Close()
+
+	replica1.ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(lagRows("0/3000000", 5))
+	replica2.ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(lagRows("0/3000000", 0.25))
+	replica2.ExpectQuery("select name").WillReturnRows(hermestest.NewRows("name").AddRow("two"))
+	replica2.ExpectQuery("select name").WillReturnRows(hermestest.NewRows("name").AddRow("two"))
+
+	if err := cluster.CheckLag(context.Background()); err != nil {
+		t.Fatalf("Unable to check replica lag: %s", err)
+	}
+
+	lags := cluster.ReplicaLag()
+	if lags[0] != 5*time.Second || lags[1] != 250*time.Millisecond {
+		t.Errorf("Unexpected replica lag %v", lags)
+	}
+
+	for idx := 0; idx < 2; idx++ {
+		var name string
+		if err := cluster.Get(&name, "select name from samples"); err != nil || name != "two" {
+			t.Errorf("Expected to skip the lagging replica; got %s, %v", name, err)
+		}
+	}
+
+	for _, mock := range []*hermestest.Mock{primary, replica1, replica2} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	primary, replica1, replica2 := hermestest.New(), hermestest.New(), hermestest.New()
+
+	cluster := hermes.NewCluster(primary.DB(hermes.WithReplicaLag(time.Hour, 0)), replica1.DB(), replica2.DB())
+	defer cluster.Close()
+
+	replica1.ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(lagRows("0/3000000", 0))
+	replica2.ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(lagRows("0/4000000", 0))
+
+	primary.ExpectExec("update samples")
+	primary.ExpectQuery("pg_current_wal_lsn").WillReturnRows(hermestest.NewRows("lsn").AddRow("0/4000000"))
+	replica2.ExpectQuery("select name").WillReturnRows(hermestest.NewRows("name").AddRow("two"))
+
+	primary.ExpectBegin()
+	primary.ExpectExec("update samples")
+	primary.ExpectCommit()
+	primary.ExpectQuery("pg_current_wal_lsn").WillReturnRows(hermestest.NewRows("lsn").AddRow("0/5000000"))
+	primary.ExpectQuery("select name").WillReturnRows(hermestest.NewRows("name").AddRow("primary"))
+
+	if err := cluster.CheckLag(context.Background()); err != nil {
+		t.Fatalf("Unable to check replica lag: %s", err)
+	}
+
+	var session hermes.Session
+	ctx := hermes.WithSession(context.Background(), &session)
+
+	if _, err := cluster.ExecContext(ctx, "update samples set name = 'Bob'"); err != nil {
+		t.Fatalf("Unable to update: %s", err)
+	}
+
+	if session.LSN().String() != "0/4000000" {
+		t.Errorf("Expected the session to record the write; got %s", session.LSN())
+	}
+
+	var name string
+	if err := cluster.GetContext(ctx, &name, "select name from samples"); err != nil || name != "two" {
+		t.Errorf("Expected to read from the replica that caught up; got %s, %v", name, err)
+	}
+
+	tx, err := cluster.BeginCtx(ctx)
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+	defer tx.Close()
+
+	if _, err := tx.Exec("update samples set name = 'Sally'"); err != nil {
+		t.Fatalf("Unable to update: %s", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Unable to commit: %s", err)
+	}
+
+	if session.LSN().String() != "0/5000000" {
+		t.Errorf("Expected the session to record the commit; got %s", session.LSN())
+	}
+
+	if err := cluster.GetContext(ctx, &name, "select name from samples"); err != nil || name != "primary" {
+		t.Errorf("Expected to fall back to the primary; got %s, %v", name, err)
+	}
+
+	for _, mock := range []*hermestest.Mock{primary, replica1, replica2} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSessionWithoutLagMonitor(t *testing.T) {
+	primary, replica1, replica2 := hermestest.New(), hermestest.New(), hermestest.New()
+
+	cluster := hermes.NewCluster(primary.DB(), replica1.DB(), replica2.DB())
+	defer cluster.Close()
+
+	replica1.ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(lagRows("0/3000000", 0))
+	replica2.ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(lagRows("0/4000000", 0))
+	replica2.ExpectQuery("select name").WillReturnRows(hermestest.NewRows("name").AddRow("two"))
+
+	var session hermes.Session
+	session.Advance(0x4000000)
+
+	ctx := hermes.WithSession(context.Background(), &session)
+
+	var name string
+	if err := cluster.GetContext(ctx, &name, "select name from samples"); err != nil || name != "two" {
+		t.Errorf("Expected to read from the replica that caught up; got %s, %v", name, err)
+	}
+
+	for _, mock := range []*hermestest.Mock{primary, replica1, replica2} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReplicaLagClockSkew(t *testing.T) {
+	primary, replica := hermestest.New(), hermestest.New()
+
+	cluster := hermes.NewCluster(primary.DB(hermes.WithReplicaLag(time.Hour, time.Second)), replica.DB())
+	defer cluster.Close()
+
+	replica.ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(lagRows("0/3000000", -0.5))
+	replica.ExpectQuery("select name").WillReturnRows(hermestest.NewRows("name").AddRow("replica"))
+
+	if err := cluster.CheckLag(context.Background()); err != nil {
+		t.Fatalf("Unable to check replica lag: %s", err)
+	}
+
+	if lags := cluster.ReplicaLag(); lags[0] != 0 {
+		t.Errorf("Expected negative lag to count as zero; got %s", lags[0])
+	}
+
+	var name string
+	if err := cluster.Get(&name, "select name from samples"); err != nil || name != "replica" {
+		t.Errorf("Expected to read from the replica; got %s, %v", name, err)
+	}
+
+	if err := replica.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSessionUnresponsiveReplica(t *testing.T) {
+	primary, replica1, replica2 := hermestest.New(), hermestest.New(), hermestest.New()
+
+	cluster := hermes.NewCluster(primary.DB(), replica1.DB(), replica2.DB())
+	defer cluster.Close()
+
+	replica1.ExpectQuery("pg_last_wal_replay_lsn").WillDelayFor(time.Minute)
+	primary.ExpectQuery("select name").WillReturnRows(hermestest.NewRows("name").AddRow("primary"))
+	replica2.ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(lagRows("0/4000000", 0))
+	replica2.ExpectQuery("select name").WillReturnRows(hermestest.NewRows("name").AddRow("two"))
+	replica2.ExpectQuery("select name").WillReturnRows(hermestest.NewRows("name").AddRow("two"))
+
+	var session hermes.Session
+	session.Advance(0x4000000)
+
+	ctx := hermes.WithSession(context.Background(), &session)
+
+	// The unresponsive replica uses up the deadline for checking replicas,
+	// so the read falls back to the primary
+	start := time.Now()
+
+	var name string
+	if err := cluster.GetContext(ctx, &name, "select name from samples"); err != nil || name != "primary" {
+		t.Errorf("Expected to fall back to the primary; got %s, %v", name, err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Errorf("Expected the replica checks to give up after a second; took %s", elapsed)
+	}
+
+	// Later reads skip the unresponsive replica
+	start = time.Now()
+
+	for idx := 0; idx < 2; idx++ {
+		if err := cluster.GetContext(ctx, &name, "select name from samples"); err != nil || name != "two" {
+			t.Errorf("Expected to read from the replica that caught up; got %s, %v", name, err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected to skip the unresponsive replica; took %s", elapsed)
+	}
+
+	for _, mock := range []*hermestest.Mock{primary, replica1, replica2} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
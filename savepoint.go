@@ -2,39 +2,134 @@ package hermes
 
 import (
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
 
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrInvalidSavepoint returned when rolling back to or releasing a
+	// savepoint that isn't active in the transaction, e.g. it was released
+	// or a rollback to an earlier savepoint destroyed it.
+	ErrInvalidSavepoint = errors.New("invalid savepoint")
+
+	// ErrSavepointName returned when a named savepoint isn't a valid SQL
+	// identifier.
+	ErrSavepointName = errors.New("invalid savepoint name")
+
+	// Savepoint names must be simple, unquoted SQL identifiers, no longer
+	// than the PostgreSQL limit of 63 characters.
+	savepointName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+)
+
 // Savepoint does nothing on the DB.
 func (db *DB) Savepoint() (string, error) {
 	id := GenerateSavepointID()
 	return id, nil
 }
 
+// NamedSavepoint does nothing on the DB, other than validate the name.
+func (db *DB) NamedSavepoint(name string) error {
+	return validateSavepoint(name)
+}
+
 // RollbackTo does nothng on DB.
 func (db *DB) RollbackTo(savepointID string) error {
 	return nil
 }
 
+// ReleaseSavepoint does nothing on DB.
+func (db *DB) ReleaseSavepoint(savepointID string) error {
+	return nil
+}
+
 // Savepoint creates a new savepoint that can be rolled back to.
 func (tx *Tx) Savepoint() (string, error) {
 	id := GenerateSavepointID()
-	_, err := tx.Exec("SAVEPOINT " + id)
-	if err != nil {
+	if err := tx.savepoint(id); err != nil {
 		return "", err
 	}
 
 	return id, nil
 }
 
-// RollbackTo rolls back to the savepoint.
+// NamedSavepoint creates a new savepoint with the given name.  The name must
+// be a valid SQL identifier, or returns ErrSavepointName.  If the name is
+// already in use, the new savepoint hides the old one until it is released.
+func (tx *Tx) NamedSavepoint(name string) error {
+	if err := validateSavepoint(name); err != nil {
+		return err
+	}
+
+	return tx.savepoint(name)
+}
+
+// RollbackTo rolls back to the savepoint.  The savepoint remains active, but
+// any savepoints created after it are destroyed.  Returns ErrInvalidSavepoint
+// if the savepoint isn't active.
 func (tx *Tx) RollbackTo(savepointID string) error {
-	_, err := tx.Exec("ROLLBACK TO SAVEPOINT " + savepointID)
+	idx, err := tx.findSavepoint(savepointID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("ROLLBACK TO SAVEPOINT " + savepointID); err != nil {
+		return err
+	}
+
+	tx.savepoints = tx.savepoints[:idx+1]
+
+	return nil
+}
+
+// ReleaseSavepoint releases the savepoint, along with any savepoints created
+// after it.  The work done since the savepoint is kept.  Returns
+// ErrInvalidSavepoint if the savepoint isn't active.
+func (tx *Tx) ReleaseSavepoint(savepointID string) error {
+	idx, err := tx.findSavepoint(savepointID)
 	if err != nil {
 		return err
 	}
 
+	if _, err := tx.Exec("RELEASE SAVEPOINT " + savepointID); err != nil {
+		return err
+	}
+
+	tx.savepoints = tx.savepoints[:idx]
+
+	return nil
+}
+
+// Creates the savepoint and pushes it on the stack of active savepoints.
+func (tx *Tx) savepoint(id string) error {
+	if _, err := tx.Exec("SAVEPOINT " + id); err != nil {
+		return err
+	}
+
+	tx.savepoints = append(tx.savepoints, id)
+
+	return nil
+}
+
+// Returns the index of the most recent active savepoint with the given ID.
+func (tx *Tx) findSavepoint(id string) (int, error) {
+	for idx := len(tx.savepoints) - 1; idx >= 0; idx-- {
+		if tx.savepoints[idx] == id {
+			return idx, nil
+		}
+	}
+
+	return -1, fmt.Errorf("%w: %s", ErrInvalidSavepoint, id)
+}
+
+// Confirms the savepoint name is a valid SQL identifier.
+func validateSavepoint(name string) error {
+	if !savepointName.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrSavepointName, name)
+	}
+
 	return nil
 }
 
@@ -1,7 +1,10 @@
 package hermes_test
 
 import (
+	"errors"
 	"testing"
+
+	"github.com/sbowman/hermes"
 )
 
 // Test using savepoints for partial rollbacks.
@@ -56,3 +59,66 @@ func TestSavepoint(t *testing.T) {
 		t.Errorf("Expected one record; got %d", count)
 	}
 }
+
+// Rolling back to an outer savepoint invalidates the inner savepoints.
+func TestSavepointStack(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Close()
+
+	if err := tx.NamedSavepoint("outer"); err != nil {
+		t.Fatalf("Unable to create outer savepoint: %s", err)
+	}
+
+	inner, err := tx.Savepoint()
+	if err != nil {
+		t.Fatalf("Unable to create inner savepoint: %s", err)
+	}
+
+	if err := tx.RollbackTo("outer"); err != nil {
+		t.Fatalf("Unable to rollback to outer savepoint: %s", err)
+	}
+
+	if err := tx.RollbackTo(inner); !errors.Is(err, hermes.ErrInvalidSavepoint) {
+		t.Errorf(`Expected error "%s"; got "%v"`, hermes.ErrInvalidSavepoint, err)
+	}
+
+	if err := tx.ReleaseSavepoint("outer"); err != nil {
+		t.Errorf("Unable to release outer savepoint: %s", err)
+	}
+
+	if err := tx.ReleaseSavepoint("outer"); !errors.Is(err, hermes.ErrInvalidSavepoint) {
+		t.Errorf(`Expected error "%s"; got "%v"`, hermes.ErrInvalidSavepoint, err)
+	}
+
+	var one int
+	if err := tx.Get(&one, "select 1"); err != nil {
+		t.Errorf("Expected the transaction to remain valid: %s", err)
+	}
+}
+
+func TestSavepointName(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Close()
+
+	for _, name := range []string{"", "1abc", "abc; drop table users", `"quoted"`} {
+		if err := tx.NamedSavepoint(name); !errors.Is(err, hermes.ErrSavepointName) {
+			t.Errorf(`Expected error "%s" for %q; got "%v"`, hermes.ErrSavepointName, name, err)
+		}
+	}
+
+	if err := tx.NamedSavepoint("before_update"); err != nil {
+		t.Errorf("Unable to create named savepoint: %s", err)
+	}
+}
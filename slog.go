@@ -0,0 +1,36 @@
+//go:build go1.21
+// +build go1.21
+
+package hermes
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogLogger adapts a *slog.Logger.  Hermes log levels map to the slog levels
+// of the same name, and fields are passed as slog attributes.  If logger is
+// nil, uses slog.Default().
+func SlogLogger(logger *slog.Logger) Logger {
+	return &slogLogger{logger: logger}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+var slogLevels = map[LogLevel]slog.Level{
+	LogDebug: slog.LevelDebug,
+	LogInfo:  slog.LevelInfo,
+	LogWarn:  slog.LevelWarn,
+	LogError: slog.LevelError,
+}
+
+func (l *slogLogger) Log(level LogLevel, msg string, fields ...interface{}) {
+	logger := l.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	logger.Log(context.Background(), slogLevels[level], msg, fields...)
+}
@@ -0,0 +1,30 @@
+//go:build go1.21
+// +build go1.21
+
+package hermes_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/sbowman/hermes"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := hermes.SlogLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	logger.Log(hermes.LogDebug, "Hidden")
+	logger.Log(hermes.LogWarn, "Database is unhealthy", "depth", 1)
+
+	out := buf.String()
+	if strings.Contains(out, "Hidden") {
+		t.Errorf("Expected debug messages to be filtered by the handler: %s", out)
+	}
+
+	if !strings.Contains(out, `level=WARN msg="Database is unhealthy" depth=1`) {
+		t.Errorf("Unexpected output: %s", out)
+	}
+}
@@ -0,0 +1,134 @@
+package hermes
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// How long to wait for an EXPLAIN of a slow query.
+const explainTimeout = 10 * time.Second
+
+// WithSlowQueryLog logs a warning for every statement that takes longer than
+// the threshold, with the query, the types of its arguments (but not their
+// values), the duration, and, in a transaction, where the transaction began.
+// For queries, the duration is the time until the rows are returned, not the
+// time to read them.
+//
+// To report where transactions began, the slow query log records the stack
+// trace of every transaction, as WithTxRegistry does.
+func WithSlowQueryLog(threshold time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.SlowQuery = threshold
+	}
+}
+
+// WithSlowQueryExplain attaches the query plan to the slow query log for
+// SELECT statements.  Hermes runs "EXPLAIN (FORMAT JSON)" with the same
+// arguments on a separate connection in the background, then logs the slow
+// query with the plan.  To avoid hammering a struggling database, at most one
+// EXPLAIN runs per interval; slow queries in between are logged without a
+// plan.  Requires WithSlowQueryLog.
+func WithSlowQueryExplain(interval time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.SlowQueryExplain = interval
+	}
+}
+
+// Hook that logs slow statements, enabled with WithSlowQueryLog.
+type slowLog struct {
+	db        *DB
+	threshold time.Duration
+	interval  time.Duration // minimum time between EXPLAINs; zero disables
+	last      int64         // time of the last EXPLAIN in Unix nanoseconds; access atomically
+}
+
+func (s *slowLog) Before(ctx context.Context, _ *QueryEvent) context.Context {
+	return ctx
+}
+
+func (s *slowLog) After(_ context.Context, event *QueryEvent) {
+	if event.Duration < s.threshold {
+		return
+	}
+
+	fields := []interface{}{
+		"op", event.Op,
+		"query", event.Query,
+		"args", redact(event.Args),
+		"duration", event.Duration,
+	}
+
+	if event.tx != nil {
+		fields = append(fields, "tx", event.tx.record.id, "depth", event.Depth)
+
+		if origin := event.tx.record.origin(); origin != "" {
+			fields = append(fields, "origin", origin)
+		}
+	}
+
+	if event.Err != nil {
+		fields = append(fields, "err", event.Err)
+	}
+
+	if !s.explainable(event) || !s.allow() {
+		s.db.logger().Log(LogWarn, "Slow query", fields...)
+		return
+	}
+
+	args := append([]interface{}(nil), event.Args...)
+
+	go func() {
+		plan, err := s.explain(event.Query, args)
+		if err != nil {
+			fields = append(fields, "explain_err", err)
+		} else {
+			fields = append(fields, "plan", plan)
+		}
+
+		s.db.logger().Log(LogWarn, "Slow query", fields...)
+	}()
+}
+
+// Only SELECT statements are explained.
+func (s *slowLog) explainable(event *QueryEvent) bool {
+	if s.interval <= 0 {
+		return false
+	}
+
+	switch event.Op {
+	case OpQuery, OpRow, OpGet, OpSelect:
+	default:
+		return false
+	}
+
+	query := strings.ToLower(strings.TrimSpace(event.Query))
+	return strings.HasPrefix(query, "select") || strings.HasPrefix(query, "with")
+}
+
+// Returns true if it's been at least the interval since the last EXPLAIN.
+func (s *slowLog) allow() bool {
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&s.last)
+
+	if last != 0 && now-last < int64(s.interval) {
+		return false
+	}
+
+	return atomic.CompareAndSwapInt64(&s.last, last, now)
+}
+
+// Runs EXPLAIN on the query, outside of any transaction and without calling
+// the hooks.
+func (s *slowLog) explain(query string, args []interface{}) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+	defer cancel()
+
+	var plan string
+	if err := s.db.base().QueryRowxContext(ctx, "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&plan); err != nil {
+		return "", err
+	}
+
+	return plan, nil
+}
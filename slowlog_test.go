@@ -0,0 +1,103 @@
+package hermes_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+// Sends each log message to a channel, formatted as the message followed by
+// the fields.
+type chanLogger chan string
+
+func (c chanLogger) Log(level hermes.LogLevel, msg string, fields ...interface{}) {
+	c <- strings.TrimSpace(fmt.Sprintln(append([]interface{}{msg}, fields...)...))
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	logs := make(chanLogger, 10)
+
+	fake := hermestest.New()
+	db := fake.DB(hermes.WithLogger(logs), hermes.WithSlowQueryLog(20*time.Millisecond))
+	defer db.Close()
+
+	fake.ExpectBegin()
+	fake.ExpectExec("update samples").WithArgs("secret").WillDelayFor(30 * time.Millisecond)
+	fake.ExpectExec("update samples").WithArgs("fast")
+	fake.ExpectRollback()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+	defer tx.Close()
+
+	if _, err := tx.Exec("update samples set name = $1", "secret"); err != nil {
+		t.Fatalf("Unable to update: %s", err)
+	}
+
+	if _, err := tx.Exec("update samples set name = $1", "fast"); err != nil {
+		t.Fatalf("Unable to update: %s", err)
+	}
+
+	if len(logs) != 1 {
+		t.Fatalf("Expected one slow query; got %d", len(logs))
+	}
+
+	msg := <-logs
+	if !strings.HasPrefix(msg, "Slow query op Exec query update samples set name = $1 args [string]") {
+		t.Errorf("Unexpected log message: %s", msg)
+	}
+
+	if strings.Contains(msg, "secret") {
+		t.Errorf("Arguments should be redacted: %s", msg)
+	}
+
+	// The slow query log records the origin without WithTxRegistry
+	if !strings.Contains(msg, "origin ") || !strings.Contains(msg, "slowlog_test.go") {
+		t.Errorf("Expected the transaction's origin: %s", msg)
+	}
+}
+
+func TestSlowQueryExplain(t *testing.T) {
+	logs := make(chanLogger, 10)
+
+	fake := hermestest.New()
+	db := fake.DB(
+		hermes.WithLogger(logs),
+		hermes.WithSlowQueryLog(20*time.Millisecond),
+		hermes.WithSlowQueryExplain(time.Hour))
+	defer db.Close()
+
+	fake.ExpectQuery("select name from samples").WithArgs(12).WillDelayFor(30 * time.Millisecond)
+	fake.ExpectQuery("EXPLAIN \\(FORMAT JSON\\) select name from samples").WithArgs(12).
+		WillReturnRows(hermestest.NewRows("QUERY PLAN").AddRow(`[{"Plan": {}}]`))
+	fake.ExpectQuery("select name from samples").WillDelayFor(30 * time.Millisecond)
+
+	var name string
+	_ = db.Get(&name, "select name from samples where id = $1", 12)
+
+	select {
+	case msg := <-logs:
+		if !strings.Contains(msg, `plan [{"Plan": {}}]`) {
+			t.Errorf("Expected the query plan: %s", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Slow query wasn't logged")
+	}
+
+	// Rate limited, so no EXPLAIN
+	_ = db.Get(&name, "select name from samples where id = $1", 12)
+
+	if msg := <-logs; strings.Contains(msg, "plan") {
+		t.Errorf("Expected the EXPLAIN to be rate limited: %s", msg)
+	}
+
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,114 @@
+package hermes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Span attribute keys, following the OpenTelemetry database conventions where
+// they apply.
+const (
+	AttrDBSystem     = "db.system"
+	AttrDBStatement  = "db.statement"
+	AttrDBOperation  = "db.operation"
+	AttrRowsAffected = "db.rows_affected"
+	AttrTxDepth      = "hermes.tx.depth"
+	AttrTxID         = "hermes.tx.id"
+	AttrTxMaxDepth   = "hermes.tx.max_depth"
+	AttrTxCommitted  = "hermes.tx.committed"
+)
+
+// Tracer creates tracing spans for transactions and statements.  Implement it
+// to adapt a tracing library; see the otel subpackage for OpenTelemetry.
+type Tracer interface {
+	// Start a span as a child of any span in the context.  Returns the
+	// context with the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single operation within a trace.
+type Span interface {
+	// SetAttribute adds a key/value pair to the span, e.g. the statement.
+	SetAttribute(key string, value interface{})
+
+	// RecordError marks the span as failed with the error.
+	RecordError(err error)
+
+	// End completes the span.
+	End()
+}
+
+// WithTracer traces every transaction and statement.  Each transaction is a
+// "hermes.Tx" span, started as a child of the context passed to BeginCtx or
+// BeginTx, and each statement is a span named for its operation, e.g.
+// "hermes.Exec", as a child of its transaction's span or, outside of a
+// transaction, the statement's context.  Statement spans carry the db.system,
+// db.statement, and db.operation attributes, the rows affected by an Exec, and
+// the error, if any.  Nested transactions share the outermost transaction's
+// span.
+func WithTracer(tracer Tracer) Option {
+	return func(cfg *Config) {
+		cfg.Tracer = tracer
+	}
+}
+
+// Hook that creates tracing spans, enabled with WithTracer.
+type tracing struct {
+	tracer Tracer
+}
+
+type spanKey struct{}
+
+func (t tracing) BeginTx(ctx context.Context, event *TxEvent) context.Context {
+	ctx, span := t.tracer.Start(ctx, "hermes.Tx")
+	span.SetAttribute(AttrDBSystem, "postgresql")
+	span.SetAttribute(AttrTxID, event.ID)
+
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func (t tracing) EndTx(ctx context.Context, event *TxEvent) {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	if !ok {
+		return
+	}
+
+	span.SetAttribute(AttrTxCommitted, event.Committed)
+	span.SetAttribute(AttrTxMaxDepth, event.MaxDepth)
+	span.End()
+}
+
+func (t tracing) Before(ctx context.Context, event *QueryEvent) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, span := t.tracer.Start(ctx, "hermes."+event.Op)
+	span.SetAttribute(AttrDBSystem, "postgresql")
+	span.SetAttribute(AttrDBStatement, event.Query)
+	span.SetAttribute(AttrDBOperation, event.Op)
+
+	if event.InTx {
+		span.SetAttribute(AttrTxDepth, event.Depth)
+	}
+
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func (t tracing) After(ctx context.Context, event *QueryEvent) {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	if !ok {
+		return
+	}
+
+	if event.RowsAffected >= 0 {
+		span.SetAttribute(AttrRowsAffected, event.RowsAffected)
+	}
+
+	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
+		span.RecordError(event.Err)
+	}
+
+	span.End()
+}
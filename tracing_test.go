@@ -0,0 +1,108 @@
+package hermes_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sbowman/hermes"
+	"github.com/sbowman/hermes/hermestest"
+)
+
+// Records the spans started, in order.
+type fakeTracer struct {
+	spans []*fakeSpan
+}
+
+type fakeSpan struct {
+	name   string
+	parent *fakeSpan
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+type fakeSpanKey struct{}
+
+func (t *fakeTracer) Start(ctx context.Context, name string) (context.Context, hermes.Span) {
+	parent, _ := ctx.Value(fakeSpanKey{}).(*fakeSpan)
+
+	span := &fakeSpan{name: name, parent: parent, attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+
+	return context.WithValue(ctx, fakeSpanKey{}, span), span
+}
+
+func (s *fakeSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *fakeSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *fakeSpan) End() {
+	s.ended = true
+}
+
+func TestTracer(t *testing.T) {
+	var tracer fakeTracer
+
+	fake := hermestest.New()
+	db := fake.DB(hermes.WithTracer(&tracer))
+	defer db.Close()
+
+	fake.ExpectBegin()
+	fake.ExpectExec("update samples").WillReturnResult(0, 3)
+	fake.ExpectExec("delete from samples").WillReturnError(errors.New("oops"))
+	fake.ExpectRollback()
+
+	ctx, request := tracer.Start(context.Background(), "request")
+
+	tx, err := db.BeginCtx(ctx)
+	if err != nil {
+		t.Fatalf("Unable to start transaction: %s", err)
+	}
+
+	if _, err := tx.Exec("update samples set name = 'Bob'"); err != nil {
+		t.Fatalf("Unable to update: %s", err)
+	}
+
+	if _, err := tx.Exec("delete from samples"); err == nil {
+		t.Fatal("Expected delete to fail")
+	}
+
+	if err := tx.Close(); err != nil {
+		t.Fatalf("Unable to close transaction: %s", err)
+	}
+
+	if len(tracer.spans) != 4 {
+		t.Fatalf("Expected four spans; got %d", len(tracer.spans))
+	}
+
+	txSpan, update, del := tracer.spans[1], tracer.spans[2], tracer.spans[3]
+
+	if txSpan.name != "hermes.Tx" || txSpan.parent != request || !txSpan.ended {
+		t.Errorf("Unexpected transaction span %+v", txSpan)
+	}
+
+	if txSpan.attrs[hermes.AttrTxCommitted] != false {
+		t.Errorf("Expected the transaction to be rolled back: %+v", txSpan.attrs)
+	}
+
+	if update.name != "hermes.Exec" || update.parent != txSpan || !update.ended {
+		t.Errorf("Unexpected statement span %+v", update)
+	}
+
+	if update.attrs[hermes.AttrDBStatement] != "update samples set name = 'Bob'" || update.attrs[hermes.AttrRowsAffected] != int64(3) {
+		t.Errorf("Unexpected statement attributes %+v", update.attrs)
+	}
+
+	if del.err == nil {
+		t.Error("Expected the failed statement to record the error")
+	}
+
+	if request.(*fakeSpan).ended {
+		t.Error("The request span shouldn't be ended by Hermes")
+	}
+}
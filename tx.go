@@ -19,6 +19,10 @@ var (
 	// ErrTxCommitted returned if the caller tries to rollback then
 	// commit a transaction in the same function.
 	ErrTxCommitted = errors.New("already committed")
+
+	// ErrTxOptions returned when a nested transaction requests options the
+	// parent transaction can't honor, such as a stricter isolation level.
+	ErrTxOptions = errors.New("transaction options incompatible with parent transaction")
 )
 
 const (
@@ -27,22 +31,43 @@ const (
 	_commit
 )
 
+// Tracks a nested transaction backed by a savepoint.
+type level struct {
+	savepoint string // created when the nested transaction began
+	commits   int    // OnCommit callbacks registered before the nested transaction
+	rollbacks int    // OnRollback callbacks registered before the nested transaction
+}
+
 // Tx wraps a sqlx.Tx transaction.  Tracks context.
 type Tx struct {
 	db       *DB
+	pool     *sqlx.DB // the connection pool the transaction runs on
 	ctx      context.Context
+	opts     *sql.TxOptions
 	internal *sqlx.Tx
 
-	current int   // current state
-	history []int // past states
+	current int     // current state
+	history []int   // past states
+	nested  bool    // back nested transactions with savepoints?
+	levels  []level // nested transactions backed by savepoints, if nested
+
+	savepoints []string // stack of active savepoints
+	onCommit   []func() // called after the database transaction commits
+	onRollback []func() // called after the database transaction rolls back
 
-	rollback bool     // is the transaction being rolled back?
-	timer    *txTimer // if TxTimeout is set, reports when Tx existence exceeds timeout
+	rollback bool      // is the transaction being rolled back?
+	finished bool      // has the database transaction committed or rolled back?
+	maxDepth int       // deepest nesting depth reached
+	record   *txRecord // tracks the open transaction in the database registry
+	timer    *txTimer  // if TxTimeout is set, reports when Tx existence exceeds timeout
+
+	event   *TxEvent        // reported to the TxHooks, if any
+	hookCtx context.Context // returned by the TxHooks
 }
 
-// BaseDB returns the base database connection.
+// BaseDB returns the base database connection the transaction runs on.
 func (tx *Tx) BaseDB() *sqlx.DB {
-	return tx.db.BaseDB()
+	return tx.pool
 }
 
 // BaseTx returns the internal sqlx transaction.
@@ -62,7 +87,10 @@ func (tx *Tx) Begin() (Conn, error) {
 		return nil, ErrTxRolledBack
 	}
 
-	tx.push()
+	if err := tx.nest(); err != nil {
+		return nil, err
+	}
+
 	return tx, nil
 }
 
@@ -78,63 +106,104 @@ func (tx *Tx) BeginCtx(ctx context.Context) (Conn, error) {
 	}
 
 	tx.ctx = ctx
-	tx.push()
+	if err := tx.nest(); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// BeginTx begins a new transaction in context with the given options.  Because
+// the nested transaction shares the parent's database transaction, returns
+// ErrTxOptions if the options request an isolation level stricter than the
+// parent's, or a read-only transaction when the parent is read-write.
+func (tx *Tx) BeginTx(ctx context.Context, opts *sql.TxOptions) (Conn, error) {
+	if tx.rollback {
+		return nil, ErrTxRolledBack
+	}
+
+	if tx.ctx != nil && tx.ctx != ctx {
+		return nil, ErrBadContext
+	}
+
+	if opts != nil {
+		if isolation(opts) > isolation(tx.opts) {
+			return nil, ErrTxOptions
+		}
+
+		if opts.ReadOnly && (tx.opts == nil || !tx.opts.ReadOnly) {
+			return nil, ErrTxOptions
+		}
+	}
+
+	tx.ctx = ctx
+	if err := tx.nest(); err != nil {
+		return nil, err
+	}
 
 	return tx, nil
 }
 
 // Exec executes a database statement with no results..
 func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.ExecContext(tx.context(), query, args...)
+}
+
+// ExecContext executes a database statement with no results, using the
+// context to cancel the request.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
 	if err := tx.ok(); err != nil {
 		return nil, err
 	}
 
-	var res sql.Result
-	var err error
-
-	if tx.ctx != nil {
-		res, err = tx.internal.ExecContext(tx.ctx, query, args...)
-	} else {
-		res, err = tx.internal.Exec(query, args...)
-	}
+	res, err := tx.runExec(ctx, query, args, func() (sql.Result, error) {
+		return tx.internal.ExecContext(ctx, query, args...)
+	})
 
 	return res, tx.check(err)
 }
 
 // Query the database.
 func (tx *Tx) Query(query string, args ...interface{}) (*sqlx.Rows, error) {
+	return tx.QueryContext(tx.context(), query, args...)
+}
+
+// QueryContext queries the database, using the context to cancel the request.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
 	if err := tx.ok(); err != nil {
 		return nil, err
 	}
 
 	var rows *sqlx.Rows
-	var err error
 
-	if tx.ctx != nil {
-		rows, err = tx.internal.QueryxContext(tx.ctx, query, args...)
-	} else {
-		rows, err = tx.internal.Queryx(query, args...)
-	}
+	err := tx.run(ctx, OpQuery, query, args, func() (err error) {
+		rows, err = tx.internal.QueryxContext(ctx, query, args...)
+		return err
+	})
 
 	return rows, tx.check(err)
 }
 
 // Row queries the databsae for a single row.
 func (tx *Tx) Row(query string, args ...interface{}) (*sqlx.Row, error) {
+	return tx.RowContext(tx.context(), query, args...)
+}
+
+// RowContext queries the database for a single row, using the context to
+// cancel the request.
+func (tx *Tx) RowContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Row, error) {
 	if err := tx.ok(); err != nil {
 		return nil, err
 	}
 
 	var row *sqlx.Row
 
-	if tx.ctx != nil {
-		row = tx.internal.QueryRowxContext(tx.ctx, query, args...)
-	} else {
-		row = tx.internal.QueryRowx(query, args...)
-	}
-
-	if row.Err() != nil {
-		return nil, tx.check(row.Err())
+	err := tx.run(ctx, OpRow, query, args, func() error {
+		row = tx.internal.QueryRowxContext(ctx, query, args...)
+		return row.Err()
+	})
+	if err != nil {
+		return nil, tx.check(err)
 	}
 
 	return row, nil
@@ -142,44 +211,58 @@ func (tx *Tx) Row(query string, args ...interface{}) (*sqlx.Row, error) {
 
 // Prepare a database query.
 func (tx *Tx) Prepare(query string) (*sqlx.Stmt, error) {
+	return tx.PrepareContext(tx.context(), query)
+}
+
+// PrepareContext prepares a database query, using the context to cancel the
+// request.
+func (tx *Tx) PrepareContext(ctx context.Context, query string) (*sqlx.Stmt, error) {
 	if err := tx.ok(); err != nil {
 		return nil, err
 	}
 
-	// TODO:  No PreparexContext?
-	//
-	// if tx.ctx != nil {
-	// 	return tx.internal.PreparexContext(tx.ctx, query, args...)
-	// }
+	var stmt *sqlx.Stmt
+
+	err := tx.run(ctx, OpPrepare, query, nil, func() (err error) {
+		stmt, err = tx.internal.PreparexContext(ctx, query)
+		return err
+	})
 
-	stmt, err := tx.internal.Preparex(query)
 	return stmt, tx.check(err)
 }
 
 // Get a single record from the database, e.g. "SELECT ... LIMIT 1".
 func (tx *Tx) Get(dest interface{}, query string, args ...interface{}) error {
+	return tx.GetContext(tx.context(), dest, query, args...)
+}
+
+// GetContext gets a single record from the database, using the context to
+// cancel the request.
+func (tx *Tx) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
 	if err := tx.ok(); err != nil {
 		return err
 	}
 
-	if tx.ctx != nil {
-		return tx.check(tx.internal.GetContext(tx.ctx, dest, query, args...))
-	}
-
-	return tx.check(tx.internal.Get(dest, query, args...))
+	return tx.check(tx.run(ctx, OpGet, query, args, func() error {
+		return tx.internal.GetContext(ctx, dest, query, args...)
+	}))
 }
 
 // Select a collection record from the database.
 func (tx *Tx) Select(dest interface{}, query string, args ...interface{}) error {
+	return tx.SelectContext(tx.context(), dest, query, args...)
+}
+
+// SelectContext selects a collection of records from the database, using the
+// context to cancel the request.
+func (tx *Tx) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
 	if err := tx.ok(); err != nil {
 		return err
 	}
 
-	if tx.ctx != nil {
-		return tx.check(tx.internal.SelectContext(tx.ctx, dest, query, args...))
-	}
-
-	return tx.check(tx.internal.Select(dest, query, args...))
+	return tx.check(tx.run(ctx, OpSelect, query, args, func() error {
+		return tx.internal.SelectContext(ctx, dest, query, args...)
+	}))
 }
 
 // Commit the current transaction.  Returns ErrTxRolledBack if the transaction
@@ -197,6 +280,12 @@ func (tx *Tx) Commit() error {
 		if err := tx.internal.Commit(); err != nil {
 			return tx.check(err)
 		}
+
+		defer tx.finish(true)
+	} else if tx.nested {
+		if err := tx.ReleaseSavepoint(tx.levels[len(tx.levels)-1].savepoint); err != nil {
+			return err
+		}
 	}
 
 	tx.current = _commit
@@ -206,6 +295,10 @@ func (tx *Tx) Commit() error {
 
 // Rollback the transaction.  Ignored if the transaction is already in a
 // rollback.  Returns ErrTxCommitted if the transaction was committed.
+//
+// If the database has NestedSavepoints enabled, rolling back a nested
+// transaction only rolls back to the savepoint created when the nested
+// transaction began.  Otherwise the entire transaction is rolled back.
 func (tx *Tx) Rollback() error {
 	if tx.rollback {
 		return nil
@@ -215,6 +308,19 @@ func (tx *Tx) Rollback() error {
 		return ErrTxCommitted
 	}
 
+	if tx.nested && len(tx.history) > 0 {
+		if tx.current == _rollback {
+			return nil
+		}
+
+		if err := tx.rollbackNested(); err != nil {
+			return err
+		}
+
+		tx.current = _rollback
+		return nil
+	}
+
 	if err := tx.internal.Rollback(); err != nil {
 		return tx.check(err)
 	}
@@ -222,6 +328,7 @@ func (tx *Tx) Rollback() error {
 	tx.current = _rollback
 	tx.rollback = true
 	tx.pop()
+	tx.finish(false)
 
 	return nil
 }
@@ -233,11 +340,19 @@ func (tx *Tx) Close() error {
 		return nil
 	}
 
+	if tx.nested && len(tx.history) > 0 {
+		err := tx.rollbackNested()
+		tx.pop()
+
+		return err
+	}
+
 	if err := tx.internal.Rollback(); err != nil {
 		tx.pop()
 
 		if err == sql.ErrTxDone {
 			tx.current = _rollback
+			tx.finish(false)
 			return nil
 		}
 
@@ -247,10 +362,18 @@ func (tx *Tx) Close() error {
 	tx.current = _rollback
 	tx.rollback = true
 	tx.pop()
+	tx.finish(false)
 
 	return nil
 }
 
+// Depth returns the nesting depth of the transaction, i.e. the number of
+// nested calls to Begin that haven't been closed.  Zero for the outermost
+// transaction.
+func (tx *Tx) Depth() int {
+	return len(tx.history)
+}
+
 // RolledBack returns true if the transaction was rolled back.
 func (tx *Tx) RolledBack() bool {
 	return tx.rollback
@@ -258,7 +381,17 @@ func (tx *Tx) RolledBack() bool {
 
 // Name returns the datasource name for this connection
 func (tx *Tx) Name() string {
-	return tx.db.name
+	return tx.db.Name()
+}
+
+// Returns the context associated with the transaction, or the background
+// context if there isn't one.
+func (tx *Tx) context() context.Context {
+	if tx.ctx == nil {
+		return context.Background()
+	}
+
+	return tx.ctx
 }
 
 // Confirm the transaction is viable before executing a query.
@@ -271,12 +404,70 @@ func (tx *Tx) ok() error {
 		return ErrTxCommitted
 	}
 
+	if tx.current == _rollback {
+		return ErrTxRolledBack
+	}
+
+	return nil
+}
+
+// Begins a nested transaction.  If nested transactions are backed by
+// savepoints, creates the savepoint for the nested transaction.
+func (tx *Tx) nest() error {
+	if !tx.nested {
+		tx.push()
+		return nil
+	}
+
+	id := GenerateSavepointID()
+	if err := tx.savepoint(id); err != nil {
+		return err
+	}
+
+	tx.push()
+	tx.levels = append(tx.levels, level{
+		savepoint: id,
+		commits:   len(tx.onCommit),
+		rollbacks: len(tx.onRollback),
+	})
+
+	return nil
+}
+
+// Rolls back the nested transaction to its savepoint, and releases the
+// savepoint.  Discards the OnCommit callbacks registered by the nested
+// transaction, and calls its OnRollback callbacks.
+func (tx *Tx) rollbackNested() error {
+	lvl := tx.levels[len(tx.levels)-1]
+
+	if err := tx.RollbackTo(lvl.savepoint); err != nil {
+		return err
+	}
+
+	if err := tx.ReleaseSavepoint(lvl.savepoint); err != nil {
+		return err
+	}
+
+	fns := tx.onRollback[lvl.rollbacks:]
+
+	tx.onCommit = tx.onCommit[:lvl.commits]
+	tx.onRollback = tx.onRollback[:lvl.rollbacks]
+
+	for _, fn := range fns {
+		fn()
+	}
+
 	return nil
 }
 
 func (tx *Tx) push() {
 	tx.history = append(tx.history, tx.current)
 	tx.current = _pending
+	tx.record.setDepth(len(tx.history))
+
+	if len(tx.history) > tx.maxDepth {
+		tx.maxDepth = len(tx.history)
+	}
 }
 
 func (tx *Tx) pop() {
@@ -290,8 +481,35 @@ func (tx *Tx) pop() {
 	}
 
 	tx.current, tx.history = tx.history[len(tx.history)-1], tx.history[:len(tx.history)-1]
+	tx.record.setDepth(len(tx.history))
+
+	if tx.nested {
+		tx.levels = tx.levels[:len(tx.levels)-1]
+	}
+}
+
+// Runs the statement function, calling the database hooks before and after.
+func (tx *Tx) run(ctx context.Context, op, query string, args []interface{}, fn func() error) error {
+	tx.record.setQuery(query)
+	return tx.db.run(ctx, op, query, args, tx, fn)
+}
+
+// Runs the Exec function, calling the database hooks before and after.
+func (tx *Tx) runExec(ctx context.Context, query string, args []interface{}, fn func() (sql.Result, error)) (sql.Result, error) {
+	tx.record.setQuery(query)
+	return tx.db.runExec(ctx, query, args, tx, fn)
 }
 
 func (tx *Tx) check(err error) error {
 	return tx.db.check(err)
 }
+
+// Returns the isolation level of the transaction options.  The default level
+// is treated as read committed, which is the PostgreSQL default.
+func isolation(opts *sql.TxOptions) sql.IsolationLevel {
+	if opts == nil || opts.Isolation == sql.LevelDefault {
+		return sql.LevelReadCommitted
+	}
+
+	return opts.Isolation
+}
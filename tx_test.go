@@ -1,6 +1,7 @@
 package hermes_test
 
 import (
+	"context"
 	"database/sql"
 	"io/ioutil"
 	"os"
@@ -64,6 +65,70 @@ func TestTransaction(t *testing.T) {
 	}
 }
 
+func TestTxExecContext(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction :%s", err)
+	}
+	defer tx.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, "select pg_sleep(1)"); err == nil {
+		t.Error("Expected the context deadline to cancel the query")
+	}
+}
+
+func TestBeginTxReadOnly(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("Unable to start read-only transaction :%s", err)
+	}
+	defer tx.Close()
+
+	if _, err := tx.Exec("create table test_read_only(wonder varchar(64))"); err == nil {
+		t.Error("Expected a read-only transaction to reject the create table")
+	}
+}
+
+func TestNestedBeginTxOptions(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		t.Fatalf("Unable to start transaction :%s", err)
+	}
+	defer tx.Close()
+
+	if _, err := tx.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable}); err != hermes.ErrTxOptions {
+		t.Errorf(`Expected error "%s"; got "%v"`, hermes.ErrTxOptions, err)
+	}
+
+	if _, err := tx.BeginTx(ctx, &sql.TxOptions{ReadOnly: true}); err != hermes.ErrTxOptions {
+		t.Errorf(`Expected error "%s"; got "%v"`, hermes.ErrTxOptions, err)
+	}
+
+	txn, err := tx.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		t.Fatalf("Unable to start nested transaction: %s", err)
+	}
+	defer txn.Close()
+
+	if err := txn.Commit(); err != nil {
+		t.Errorf("Unable to commit nested transaction: %s", err)
+	}
+}
+
 // Will transactions alert if it lasts too long (in dev mode)?
 func TestTxTimer(t *testing.T) {
 	timeout := 100 * time.Millisecond
@@ -372,3 +437,135 @@ func TestDeepAutoRollback(t *testing.T) {
 		t.Error("Unexpected results; was table cleared?")
 	}
 }
+
+func TestNestedSavepointRollback(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	db.NestedSavepoints = true
+
+	if _, err := db.Exec("create table test_nested_r(wonder varchar(64))"); err != nil {
+		t.Fatalf("Unable to create test_nested_r table: %s", err)
+	}
+	defer func() {
+		db.Exec("drop table test_nested_r")
+	}()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction :%s", err)
+	}
+	defer tx.Close()
+
+	if _, err := tx.Exec("insert into test_nested_r values ($1)", "Mahogany"); err != nil {
+		t.Errorf("Unable to insert via transaction: %s", err)
+	}
+
+	err = func(conn hermes.Conn) error {
+		txn, err := conn.Begin()
+		if err != nil {
+			return err
+		}
+		defer txn.Close()
+
+		if _, err = txn.Exec("insert into test_nested_r values ($1)", "Oak"); err != nil {
+			return err
+		}
+
+		if err := txn.Rollback(); err != nil {
+			return err
+		}
+
+		if _, err := txn.Exec("select 1"); err != hermes.ErrTxRolledBack {
+			t.Errorf(`Expected error "%s"; got "%v"`, hermes.ErrTxRolledBack, err)
+		}
+
+		return nil
+	}(tx)
+
+	if err != nil {
+		t.Fatalf("Nested tx failed unexpectedly: %s", err)
+	}
+
+	if tx.RolledBack() {
+		t.Error("Expected only the nested transaction to be rolled back")
+	}
+
+	var wonders []string
+	if err := tx.Select(&wonders, "select wonder from test_nested_r"); err != nil {
+		t.Fatalf("Failed to query database: %s", err)
+	}
+
+	if len(wonders) != 1 || wonders[0] != "Mahogany" {
+		t.Errorf("Expected only Mahogany; got %v", wonders)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Errorf("Unable to commit transaction: %s", err)
+	}
+}
+
+func TestNestedSavepointAutoRollback(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	db.NestedSavepoints = true
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction :%s", err)
+	}
+	defer tx.Close()
+
+	err = func(conn hermes.Conn) error {
+		txn, err := conn.Begin()
+		if err != nil {
+			return err
+		}
+		defer txn.Close()
+
+		// Aborts the transaction; only a rollback to the savepoint will
+		// recover it
+		_, err = txn.Exec("select * from missing_table")
+		return err
+	}(tx)
+
+	if err == nil {
+		t.Fatal("Expected the nested transaction to fail")
+	}
+
+	var one int
+	if err := tx.Get(&one, "select 1"); err != nil {
+		t.Errorf("Expected the parent transaction to recover: %s", err)
+	}
+}
+
+func TestNestedSavepointCommit(t *testing.T) {
+	db := connect(t)
+	defer db.Close()
+
+	db.NestedSavepoints = true
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start transaction :%s", err)
+	}
+	defer tx.Close()
+
+	txn, err := tx.Begin()
+	if err != nil {
+		t.Fatalf("Unable to start nested transaction: %s", err)
+	}
+
+	if err := txn.Commit(); err != nil {
+		t.Errorf("Unable to commit nested transaction: %s", err)
+	}
+
+	if err := txn.Close(); err != nil {
+		t.Errorf("Unable to close nested transaction: %s", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Errorf("Unable to commit transaction: %s", err)
+	}
+}